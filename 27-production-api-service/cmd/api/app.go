@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"example.com/production-api/internal/audit"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/handlers"
+	"example.com/production-api/internal/idempotency"
+	"example.com/production-api/internal/logging"
+	"example.com/production-api/internal/policy"
+	"example.com/production-api/internal/ratelimit"
+	"example.com/production-api/internal/repository"
+	"example.com/production-api/internal/services"
+
+	"go.uber.org/fx"
+)
+
+// appModules are the fx modules shared by the server and every admin
+// command that works with domain data
+func appModules(cfg *config.Config) fx.Option {
+	return fx.Options(
+		fx.Supply(cfg),
+		logging.Module,
+		repository.Module(cfg),
+		auth.Module,
+		policy.Module,
+		ratelimit.Module(cfg),
+		idempotency.Module(cfg),
+		audit.Module(cfg),
+		services.Module,
+		handlers.Module,
+		// Leave time for the database to come up
+		fx.StartTimeout(cfg.App.StartTimeout),
+	)
+}
+
+// runApp starts a short-lived fx application, runs fn and stops the
+// application again. Use fx.Populate in opts to get at dependencies.
+func runApp(ctx context.Context, fn func(ctx context.Context) error, opts ...fx.Option) error {
+	app := fx.New(append(opts, fx.NopLogger)...)
+	if err := app.Err(); err != nil {
+		return err
+	}
+
+	startCtx, cancel := context.WithTimeout(ctx, app.StartTimeout())
+	defer cancel()
+	if err := app.Start(startCtx); err != nil {
+		return err
+	}
+
+	runErr := fn(ctx)
+
+	if err := app.Stop(context.Background()); err != nil && runErr == nil {
+		return err
+	}
+	return runErr
+}
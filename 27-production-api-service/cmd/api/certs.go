@@ -0,0 +1,101 @@
+package main
+
+import (
+	"example.com/production-api/internal/certs"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newCertsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Manage development TLS certificates",
+	}
+
+	var (
+		dir      string
+		hosts    []string
+		clients  []string
+		validFor time.Duration
+	)
+	generate := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a self-signed CA, a server certificate and client certificates for development",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(hosts) == 0 {
+				return fmt.Errorf("at least one --host is required")
+			}
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+
+			ca, err := certs.NewAuthority("production-api development CA", validFor)
+			if err != nil {
+				return err
+			}
+			certPEM, keyPEM, err := ca.PEM()
+			if err != nil {
+				return err
+			}
+			if err := writePair(dir, "ca", certPEM, keyPEM); err != nil {
+				return err
+			}
+
+			if certPEM, keyPEM, err = ca.IssueServer(hosts, validFor); err != nil {
+				return err
+			}
+			if err := writePair(dir, "server", certPEM, keyPEM); err != nil {
+				return err
+			}
+
+			for _, name := range clients {
+				if certPEM, keyPEM, err = ca.IssueClient(name, validFor); err != nil {
+					return err
+				}
+				if err := writePair(dir, "client-"+name, certPEM, keyPEM); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("Certificates written to %s. Serve them with:\n\n", dir)
+			fmt.Printf("  APP_SERVER_TLS_CERTFILE=%s APP_SERVER_TLS_KEYFILE=%s \\\n",
+				filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"))
+			fmt.Printf("  APP_SERVER_TLS_CLIENTAUTH=optional APP_SERVER_TLS_CLIENTCAFILE=%s\n",
+				filepath.Join(dir, "ca.pem"))
+			return nil
+		},
+	}
+	generate.Flags().StringVar(&dir, "dir", "certs", "output directory")
+	generate.Flags().StringSliceVar(&hosts, "host", []string{"localhost", "127.0.0.1", "::1"}, "DNS name or IP address of the server (repeatable)")
+	generate.Flags().StringSliceVar(&clients, "client", nil, "common name of a client certificate to issue (repeatable)")
+	generate.Flags().DurationVar(&validFor, "valid-for", 365*24*time.Hour, "certificate lifetime")
+
+	cmd.AddCommand(generate)
+	return cmd
+}
+
+// writePair writes name.pem and name-key.pem to dir. Existing files are
+// never overwritten.
+func writePair(dir, name string, certPEM, keyPEM []byte) error {
+	if err := writeNew(filepath.Join(dir, name+".pem"), certPEM, 0o644); err != nil {
+		return err
+	}
+	return writeNew(filepath.Join(dir, name+"-key.pem"), keyPEM, 0o600)
+}
+
+func writeNew(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
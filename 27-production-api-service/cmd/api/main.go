@@ -2,19 +2,56 @@ package main
 
 import (
 	"example.com/production-api/internal/config"
-	"example.com/production-api/internal/database"
-	"example.com/production-api/internal/handlers"
-	"example.com/production-api/internal/server"
+	"fmt"
+	"os"
 
-	"go.uber.org/fx"
+	"github.com/spf13/cobra"
 )
 
+// cfg is loaded once by the root command before any subcommand runs
+var cfg *config.Config
+
+// configFile is the --config flag shared by every command
+var configFile string
+
 func main() {
-	fx.New(
-		// Modules
-		config.Module,
-		database.Module,
-		handlers.Module,
-		server.Module,
-	).Run()
+	if err := newRootCommand().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "api",
+		Short: "Production API service and admin tools",
+		// Running without a subcommand starts the server
+		Args:          cobra.NoArgs,
+		RunE:          runServe,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			c, err := config.New(configFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			cfg = c
+			return nil
+		},
+	}
+
+	root.PersistentFlags().StringVar(&configFile, "config", "", "config file (default ./config.yaml or ./config/config.yaml)")
+
+	root.AddCommand(
+		newServeCommand(),
+		newMigrateCommand(),
+		newSeedCommand(),
+		newUserCommand(),
+		newAPIKeyCommand(),
+		newConfigCommand(),
+		newCertsCommand(),
+		newRoutesCommand(),
+	)
+
+	return root
 }
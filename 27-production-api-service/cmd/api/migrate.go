@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"example.com/production-api/internal/database"
+	"example.com/production-api/internal/logging"
+	"example.com/production-api/internal/migrate"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+)
+
+func newMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database schema migrations",
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply all pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: withMigrator(func(ctx context.Context, m *migrate.Runner, args []string) error {
+				return m.Up(ctx)
+			}),
+		},
+		&cobra.Command{
+			Use:   "down [steps]",
+			Short: "Revert the last migrations (default 1)",
+			Args:  cobra.MaximumNArgs(1),
+			RunE: withMigrator(func(ctx context.Context, m *migrate.Runner, args []string) error {
+				steps := 1
+				if len(args) == 1 {
+					n, err := strconv.Atoi(args[0])
+					if err != nil {
+						return fmt.Errorf("invalid step count %q", args[0])
+					}
+					steps = n
+				}
+				return m.Down(ctx, steps)
+			}),
+		},
+		&cobra.Command{
+			Use:   "goto <version>",
+			Short: "Migrate up or down to a version (0 reverts everything)",
+			Args:  cobra.ExactArgs(1),
+			RunE: withMigrator(func(ctx context.Context, m *migrate.Runner, args []string) error {
+				version, err := parseVersion(args[0])
+				if err != nil {
+					return err
+				}
+				return m.Goto(ctx, version)
+			}),
+		},
+		&cobra.Command{
+			Use:   "force <version>",
+			Short: "Discard a dirty migration so it runs again",
+			Args:  cobra.ExactArgs(1),
+			RunE: withMigrator(func(ctx context.Context, m *migrate.Runner, args []string) error {
+				version, err := parseVersion(args[0])
+				if err != nil {
+					return err
+				}
+				return m.Force(ctx, version)
+			}),
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "List migrations and their state",
+			Args:  cobra.NoArgs,
+			RunE: withMigrator(func(ctx context.Context, m *migrate.Runner, args []string) error {
+				statuses, err := m.Status(ctx)
+				if err != nil {
+					return err
+				}
+				printStatus(statuses)
+				return nil
+			}),
+		},
+	)
+
+	return cmd
+}
+
+// withMigrator runs fn with a migration runner built from the database
+// module alone, so it works against an unmigrated schema
+func withMigrator(fn func(ctx context.Context, m *migrate.Runner, args []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		var runner *migrate.Runner
+		return runApp(cmd.Context(), func(ctx context.Context) error {
+			return fn(ctx, runner, args)
+		},
+			fx.Supply(cfg),
+			logging.Module,
+			database.Module,
+			fx.StartTimeout(cfg.App.StartTimeout),
+			fx.Populate(&runner),
+		)
+	}
+}
+
+func parseVersion(s string) (uint, error) {
+	version, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %q", s)
+	}
+	return uint(version), nil
+}
+
+func printStatus(statuses []migrate.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Dirty:
+			state = "dirty"
+		case s.Unknown:
+			state = "unknown"
+		case s.Drifted:
+			state = "drifted"
+		case s.Applied:
+			state = "applied"
+		}
+
+		appliedAt := ""
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"example.com/production-api/internal/cors"
+	"example.com/production-api/internal/health"
+	"example.com/production-api/internal/metrics"
+	"example.com/production-api/internal/repository"
+	"example.com/production-api/internal/server"
+	"example.com/production-api/internal/tracing"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+)
+
+func newRoutesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "routes",
+		Short: "List every route registered on the router",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Routes don't depend on data, so build the router on the
+			// in-memory repositories and skip the database entirely
+			routesCfg := *cfg
+			routesCfg.Database.Driver = repository.DriverMemory
+			routesCfg.Metrics.Enabled = false
+			routesCfg.Tracing.Exporter = tracing.ExporterNone
+
+			var router chi.Router
+			return runApp(cmd.Context(), func(ctx context.Context) error {
+				return printRoutes(router)
+			},
+				appModules(&routesCfg),
+				metrics.Module(&routesCfg),
+				tracing.Module(&routesCfg),
+				health.Module(&routesCfg),
+				cors.Module,
+				fx.Provide(server.NewRouter),
+				fx.Populate(&router),
+			)
+		},
+	}
+}
+
+func printRoutes(router chi.Router) error {
+	type route struct {
+		method, pattern string
+		middlewares     int
+	}
+
+	var routes []route
+	err := chi.Walk(router, func(method, pattern string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		pattern = strings.Replace(pattern, "/*/", "/", -1)
+		routes = append(routes, route{method, pattern, len(middlewares)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].pattern != routes[j].pattern {
+			return routes[i].pattern < routes[j].pattern
+		}
+		return routes[i].method < routes[j].method
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tROUTE\tMIDDLEWARE")
+	for _, r := range routes {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", r.method, r.pattern, r.middlewares)
+	}
+	return w.Flush()
+}
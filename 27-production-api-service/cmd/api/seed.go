@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/services"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+)
+
+// seedUsers is the sample data inserted by `api seed`
+var seedUsers = []struct {
+	user  models.User
+	posts []models.Post
+}{
+	{
+		user: models.User{Name: "Alice Johnson", Email: "alice@example.com"},
+		posts: []models.Post{
+			{Title: "Getting started with Go", Content: "Go is a simple language.", Published: true},
+			{Title: "Draft: dependency injection with fx", Content: "Work in progress."},
+		},
+	},
+	{
+		user: models.User{Name: "Bob Smith", Email: "bob@example.com"},
+		posts: []models.Post{
+			{Title: "GORM tips", Content: "Always pass a context.", Published: true},
+		},
+	},
+	{
+		user: models.User{Name: "Carol White", Email: "carol@example.com"},
+	},
+}
+
+func newSeedCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "seed",
+		Short: "Insert sample users and posts (skips users that already exist)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				users *services.UserService
+				posts *services.PostService
+			)
+			return runApp(cmd.Context(), func(ctx context.Context) error {
+				return seed(ctx, users, posts)
+			},
+				appModules(cfg),
+				fx.Populate(&users, &posts),
+			)
+		},
+	}
+}
+
+func seed(ctx context.Context, users *services.UserService, posts *services.PostService) error {
+	for _, s := range seedUsers {
+		user := s.user
+		err := users.Create(ctx, &user)
+		if errors.Is(err, services.ErrEmailTaken) {
+			fmt.Printf("skipped %s (already exists)\n", user.Email)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("seed user %s: %w", user.Email, err)
+		}
+
+		for _, p := range s.posts {
+			post := p
+			post.UserID = user.ID
+			if err := posts.Create(ctx, &post); err != nil {
+				return fmt.Errorf("seed post %q: %w", post.Title, err)
+			}
+		}
+		fmt.Printf("created %s with %d posts\n", user.Email, len(s.posts))
+	}
+	return nil
+}
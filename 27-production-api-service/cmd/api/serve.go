@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/cors"
+	"example.com/production-api/internal/health"
+	"example.com/production-api/internal/logging"
+	"example.com/production-api/internal/metrics"
+	"example.com/production-api/internal/ratelimit"
+	"example.com/production-api/internal/server"
+	"example.com/production-api/internal/tracing"
+)
+
+// stopMargin is the time other OnStop hooks get after the HTTP server
+// stopped, e.g. to flush spans
+const stopMargin = 5 * time.Second
+
+func newServeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Start the HTTP server",
+		Args:  cobra.NoArgs,
+		RunE:  runServe,
+	}
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	app := fx.New(
+		appModules(cfg),
+		metrics.Module(cfg),
+		tracing.Module(cfg),
+		health.Module(cfg),
+		cors.Module,
+		server.Module,
+		config.WatchModule,
+		fx.Invoke(subscribeReloads),
+		// Keep last: its OnStart hook must run after every other one
+		fx.Invoke(markStarted),
+		// Leave room for the drain delay and the shutdown timeout
+		fx.StopTimeout(cfg.Health.DrainDelay+cfg.Server.ShutdownTimeout+stopMargin),
+	)
+	if err := app.Err(); err != nil {
+		return err
+	}
+
+	app.Run()
+	return nil
+}
+
+// subscribeReloads applies the settings that can change while the server
+// runs; everything else waits for a restart
+func subscribeReloads(w *config.Watcher, level *logging.Level, limiter *ratelimit.Limiter, c *cors.CORS) {
+	w.Subscribe(level.Apply)
+	w.Subscribe(limiter.Apply)
+	w.Subscribe(c.Apply)
+}
+
+// markStarted completes the startup probe once every other OnStart hook
+// has run. fx runs hooks in the order they were appended, so it must be
+// the last option given to fx.New.
+func markStarted(lc fx.Lifecycle, probes *health.Probes) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			probes.Started()
+			return nil
+		},
+	})
+}
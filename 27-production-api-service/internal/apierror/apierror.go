@@ -0,0 +1,175 @@
+// Package apierror renders API errors as RFC 7807 problem details
+// (application/problem+json) with stable, machine-readable codes.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"example.com/production-api/internal/logging"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ContentType is the media type of problem detail responses
+const ContentType = "application/problem+json"
+
+// Code is a stable error identifier clients can switch on.
+// Codes are part of the API contract: add new ones, never rename.
+type Code string
+
+// Error codes
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeInvalidJSON          Code = "invalid_json"
+	CodeValidation           Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeNotAllowed           Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodeDuplicate            Code = "duplicate_resource"
+	CodeRateLimited          Code = "rate_limited"
+	CodeTooLarge             Code = "payload_too_large"
+	CodeIdempotencyMismatch  Code = "idempotency_key_mismatch"
+	CodeInProgress           Code = "request_in_progress"
+	CodePreconditionFailed   Code = "precondition_failed"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeInvalidPatch         Code = "invalid_patch"
+	CodeInternal             Code = "internal_error"
+)
+
+// FieldError describes a single invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details object extended with a code,
+// field errors and the request ID for support lookups.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+
+	// cause is logged for server errors but never sent to clients
+	cause error
+}
+
+// Error implements the error interface
+func (p *Problem) Error() string {
+	if p.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", p.Code, p.Detail, p.cause)
+	}
+	return fmt.Sprintf("%s: %s", p.Code, p.Detail)
+}
+
+// Unwrap returns the underlying cause
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+// New creates a problem with the given status, code and detail
+func New(status int, code Code, detail string) *Problem {
+	return &Problem{
+		Type:   "/problems/" + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// WithCause attaches the underlying error for logging
+func (p *Problem) WithCause(err error) *Problem {
+	p.cause = err
+	return p
+}
+
+// BadRequest reports a malformed request
+func BadRequest(detail string) *Problem {
+	return New(http.StatusBadRequest, CodeBadRequest, detail)
+}
+
+// InvalidJSON reports a request body that could not be decoded. A body
+// cut off by the body limit is reported as too large instead.
+func InvalidJSON(err error) *Problem {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return TooLarge(tooLarge.Limit).WithCause(err)
+	}
+	return New(http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON").WithCause(err)
+}
+
+// UnreadableBody reports a request body that could not be read, either
+// because it exceeds the body limit or because the client went away
+func UnreadableBody(err error) *Problem {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return TooLarge(tooLarge.Limit).WithCause(err)
+	}
+	return BadRequest("could not read request body").WithCause(err)
+}
+
+// TooLarge reports a request body over limit bytes
+func TooLarge(limit int64) *Problem {
+	return New(http.StatusRequestEntityTooLarge, CodeTooLarge, fmt.Sprintf("request body exceeds %d bytes", limit))
+}
+
+// Unauthorized reports missing or invalid credentials
+func Unauthorized(detail string) *Problem {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+// Forbidden reports an authenticated caller lacking permission
+func Forbidden(detail string) *Problem {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+// NotFound reports a missing resource
+func NotFound(detail string) *Problem {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+// Conflict reports a request that conflicts with current state
+func Conflict(detail string) *Problem {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+// PreconditionFailed reports a conditional request whose If-Match did
+// not match the current resource
+func PreconditionFailed(detail string) *Problem {
+	return New(http.StatusPreconditionFailed, CodePreconditionFailed, detail)
+}
+
+// Internal reports an unexpected server error without leaking its cause
+func Internal(err error) *Problem {
+	return New(http.StatusInternalServerError, CodeInternal, "an unexpected error occurred").WithCause(err)
+}
+
+// Write maps err to a problem and writes it as the response.
+// Server errors are logged with their cause through the request logger.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	p := From(err)
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.GetReqID(r.Context())
+
+	if p.Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error().
+			Err(p.cause).
+			Str("code", string(p.Code)).
+			Msg("Request failed")
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
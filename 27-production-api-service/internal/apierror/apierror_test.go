@@ -0,0 +1,85 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+func TestFromMapsKnownErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   Code
+	}{
+		{"problem passes through", Conflict("taken"), http.StatusConflict, CodeConflict},
+		{"record not found", fmt.Errorf("load user: %w", gorm.ErrRecordNotFound), http.StatusNotFound, CodeNotFound},
+		{"unique violation", &pgconn.PgError{Code: "23505", ConstraintName: "idx_users_email"}, http.StatusConflict, CodeDuplicate},
+		{"gorm duplicated key", gorm.ErrDuplicatedKey, http.StatusConflict, CodeDuplicate},
+		{"body over limit", fmt.Errorf("read: %w", &http.MaxBytesError{Limit: 10}), http.StatusRequestEntityTooLarge, CodeTooLarge},
+		{"invalid json over limit", InvalidJSON(&http.MaxBytesError{Limit: 10}), http.StatusRequestEntityTooLarge, CodeTooLarge},
+		{"unknown error", errors.New("boom"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := From(tt.err)
+			if p.Status != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("From(%v) = %d %s; want %d %s", tt.err, p.Status, p.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidationFieldErrors(t *testing.T) {
+	type input struct {
+		Name  string `validate:"required,min=2"`
+		Email string `validate:"required,email"`
+	}
+
+	err := validator.New().Struct(input{Name: "a", Email: "nope"})
+	p := From(err)
+
+	if p.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d; want 422", p.Status)
+	}
+	if len(p.Errors) != 2 {
+		t.Fatalf("got %d field errors; want 2", len(p.Errors))
+	}
+	if got := p.Errors[0]; got.Field != "Name" || got.Rule != "min" || got.Param != "2" {
+		t.Errorf("Errors[0] = %+v; want Name/min/2", got)
+	}
+	if got := p.Errors[1]; got.Field != "Email" || got.Rule != "email" {
+		t.Errorf("Errors[1] = %+v; want Email/email", got)
+	}
+}
+
+func TestWriteHidesInternalCause(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/users/1", nil)
+	w := httptest.NewRecorder()
+
+	Write(w, r, errors.New("pq: password authentication failed"))
+
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q; want %q", ct, ContentType)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["detail"] != "an unexpected error occurred" {
+		t.Errorf("detail = %v; internal cause must not leak", body["detail"])
+	}
+	if body["instance"] != "/api/users/1" {
+		t.Errorf("instance = %v; want /api/users/1", body["instance"])
+	}
+}
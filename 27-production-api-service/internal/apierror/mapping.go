@@ -0,0 +1,105 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Postgres SQLSTATE codes mapped to client errors
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// From converts any error into a problem. Known error types are mapped
+// to client errors; everything else becomes a 500.
+func From(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		cp := *problem
+		return &cp
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return TooLarge(tooLarge.Limit).WithCause(err)
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return Validation(validationErrs)
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NotFound("resource not found").WithCause(err)
+	}
+
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return New(http.StatusConflict, CodeDuplicate, "resource already exists").WithCause(err)
+	}
+
+	if errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return Conflict("resource conflicts with related resources").WithCause(err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return New(http.StatusConflict, CodeDuplicate, uniqueDetail(pgErr)).WithCause(err)
+		case pgForeignKeyViolation:
+			return Conflict("resource conflicts with related resources").WithCause(err)
+		}
+	}
+
+	return Internal(err)
+}
+
+// uniqueDetail names the conflicting column when Postgres reports it
+func uniqueDetail(pgErr *pgconn.PgError) string {
+	if pgErr.ColumnName != "" {
+		return fmt.Sprintf("a resource with this %s already exists", pgErr.ColumnName)
+	}
+	switch pgErr.ConstraintName {
+	case "idx_users_email", "users_email_key", "users_email_active_key":
+		return "a resource with this email already exists"
+	}
+	return "resource already exists"
+}
+
+// Validation converts validator errors into a 422 problem with one
+// entry per invalid field
+func Validation(errs validator.ValidationErrors) *Problem {
+	p := New(http.StatusUnprocessableEntity, CodeValidation, "request validation failed")
+	for _, fe := range errs {
+		p.Errors = append(p.Errors, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return p
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+}
@@ -0,0 +1,176 @@
+// Package audit records who changed users and posts, and how. Writes
+// through GORM are captured by callbacks in the same transaction; the
+// in-memory repositories report theirs through an observer.
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/pagination"
+	"example.com/production-api/internal/repository"
+	"reflect"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/fx"
+)
+
+// Actions recorded in Entry.Action
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// audited lists the tables whose changes are recorded
+var audited = map[string]bool{"users": true, "posts": true}
+
+// ignored fields change on every write or are related records with their
+// own entries
+var ignored = map[string]bool{"updated_at": true, "user": true, "posts": true}
+
+// Entry is one recorded change to a user or post
+type Entry struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	Resource   string `gorm:"size:50;not null" json:"resource"`
+	ResourceID uint   `gorm:"not null" json:"resource_id"`
+	Action     string `gorm:"size:20;not null" json:"action"`
+	// ActorID and APIKeyID identify the caller; zero for anonymous
+	// requests and admin commands
+	ActorID   uint      `gorm:"not null;default:0" json:"actor_id"`
+	APIKeyID  uint      `gorm:"not null;default:0" json:"api_key_id,omitempty"`
+	RequestID string    `gorm:"size:100;not null;default:''" json:"request_id,omitempty"`
+	Changes   Changes   `gorm:"type:jsonb;not null" json:"changes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name
+func (Entry) TableName() string {
+	return "audit_entries"
+}
+
+// Change is a field's value before and after a write, as it appears in
+// the API. Before is null on create and After on purge.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Changes maps JSON field names to their changes. It is stored as JSON.
+type Changes map[string]Change
+
+// Value implements driver.Valuer
+func (c Changes) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner
+func (c *Changes) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	case nil:
+		*c = nil
+		return nil
+	}
+	return errors.New("audit: unsupported changes type")
+}
+
+// Store reads audit entries
+type Store interface {
+	List(ctx context.Context, req *pagination.Request) ([]Entry, *pagination.Page, error)
+}
+
+// Module records changes for the configured database driver and
+// provides the Store
+func Module(cfg *config.Config) fx.Option {
+	if cfg.Database.Driver == repository.DriverMemory {
+		return fx.Options(
+			fx.Provide(NewMemoryStore),
+			fx.Provide(func(s *MemoryStore) Store { return s }),
+			fx.Invoke(func(repo *repository.MemoryStore, s *MemoryStore) {
+				repo.Observe(s.Record)
+			}),
+		)
+	}
+
+	return fx.Options(
+		fx.Provide(NewPostgresStore),
+		fx.Invoke(Register),
+	)
+}
+
+// snapshot is a record as the API shows it
+type snapshot map[string]interface{}
+
+// snapshotOf converts a model into its API representation
+func snapshotOf(v interface{}) (snapshot, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var s snapshot
+	return s, json.Unmarshal(data, &s)
+}
+
+// newEntry describes the change from before to after, either of which
+// may be nil. It returns nil if nothing worth recording changed.
+func newEntry(ctx context.Context, resource string, before, after snapshot) *Entry {
+	changes := Changes{}
+	for field, value := range before {
+		if !ignored[field] && !reflect.DeepEqual(value, after[field]) {
+			changes[field] = Change{Before: value, After: after[field]}
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok && !ignored[field] && value != nil {
+			changes[field] = Change{Before: nil, After: value}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	entry := &Entry{
+		Resource:  resource,
+		Action:    action(before, after),
+		RequestID: middleware.GetReqID(ctx),
+		Changes:   changes,
+		CreatedAt: time.Now(),
+	}
+	if id, ok := after["id"].(float64); ok {
+		entry.ResourceID = uint(id)
+	} else if id, ok := before["id"].(float64); ok {
+		entry.ResourceID = uint(id)
+	}
+	if p := auth.PrincipalFrom(ctx); p != nil {
+		entry.ActorID = p.UserID
+		entry.APIKeyID = p.APIKeyID
+	}
+	return entry
+}
+
+// action names a change; soft deletes and restores are updates of
+// deleted_at
+func action(before, after snapshot) string {
+	switch {
+	case before == nil:
+		return ActionCreate
+	case after == nil:
+		return ActionPurge
+	case before["deleted_at"] == nil && after["deleted_at"] != nil:
+		return ActionDelete
+	case before["deleted_at"] != nil && after["deleted_at"] == nil:
+		return ActionRestore
+	}
+	return ActionUpdate
+}
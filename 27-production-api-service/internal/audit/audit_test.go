@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/models"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"gorm.io/gorm"
+)
+
+func TestRecordActions(t *testing.T) {
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: 7, APIKeyID: 3})
+	ctx = context.WithValue(ctx, middleware.RequestIDKey, "req-1")
+
+	live := models.Post{ID: 2, UserID: 1, Title: "Hello", Version: 1}
+	deleted := live
+	deleted.Version = 2
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	touched := live
+	touched.UpdatedAt = time.Now()
+
+	s := NewMemoryStore()
+	s.Record(ctx, "posts", nil, live)
+	s.Record(ctx, "posts", live, touched)
+	s.Record(ctx, "posts", live, deleted)
+	s.Record(ctx, "posts", deleted, live)
+	s.Record(ctx, "posts", deleted, nil)
+	s.Record(ctx, "refresh_tokens", nil, models.RefreshToken{ID: 1})
+
+	want := []string{ActionCreate, ActionDelete, ActionRestore, ActionPurge}
+	if len(s.entries) != len(want) {
+		t.Fatalf("got %d entries; want %d", len(s.entries), len(want))
+	}
+	for i, e := range s.entries {
+		if e.Action != want[i] || e.Resource != "posts" || e.ResourceID != 2 {
+			t.Errorf("entry %d = %s %s/%d; want %s posts/2", i, e.Action, e.Resource, e.ResourceID, want[i])
+		}
+		if e.ActorID != 7 || e.APIKeyID != 3 || e.RequestID != "req-1" {
+			t.Errorf("entry %d by %d/%d in %q; want 7/3 in req-1", i, e.ActorID, e.APIKeyID, e.RequestID)
+		}
+	}
+
+	if c := s.entries[1].Changes["version"]; c.Before != float64(1) || c.After != float64(2) {
+		t.Errorf("delete version change = %+v; want 1 to 2", c)
+	}
+	if c := s.entries[3].Changes["title"]; c.Before != "Hello" || c.After != nil {
+		t.Errorf("purge title change = %+v; want Hello to null", c)
+	}
+}
+
+func TestChangesRoundTrip(t *testing.T) {
+	in := Changes{"name": {Before: "Alice", After: "Alicia"}}
+	v, err := in.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out Changes
+	if err := out.Scan([]byte(v.(string))); err != nil {
+		t.Fatal(err)
+	}
+	if out["name"] != in["name"] {
+		t.Errorf("got %+v; want %+v", out, in)
+	}
+}
@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"context"
+	"example.com/production-api/internal/pagination"
+	"reflect"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// beforeKey holds the rows a statement is about to change
+const beforeKey = "audit:before"
+
+// Register installs GORM callbacks that record every create, update and
+// delete of an audited table. Entries are written in the transaction of
+// the change, so a rolled back write leaves no entry behind.
+func Register(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:commit_or_rollback_transaction").Register("audit:after_create", afterWrite); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("audit:before_update", beforeWrite); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:commit_or_rollback_transaction").Register("audit:after_update", afterWrite); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("audit:before_delete", beforeWrite); err != nil {
+		return err
+	}
+	return cb.Delete().Before("gorm:commit_or_rollback_transaction").Register("audit:after_delete", afterWrite)
+}
+
+func audits(tx *gorm.DB) bool {
+	return tx.Error == nil && tx.Statement.Schema != nil && audited[tx.Statement.Table]
+}
+
+// beforeWrite loads the rows matched by an update or delete
+func beforeWrite(tx *gorm.DB) {
+	if !audits(tx) {
+		return
+	}
+
+	var conds []clause.Expression
+	if where, ok := tx.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+		conds = append(conds, where)
+	}
+	if ids := primaryKeys(tx); len(ids) > 0 {
+		conds = append(conds, clause.IN{Column: clause.PrimaryColumn, Values: ids})
+	}
+	if len(conds) == 0 {
+		// GORM refuses global updates and deletes
+		return
+	}
+
+	rows, err := load(tx, conds...)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	tx.InstanceSet(beforeKey, rows)
+}
+
+// afterWrite reloads the changed rows and records the difference
+func afterWrite(tx *gorm.DB) {
+	if !audits(tx) || tx.RowsAffected == 0 {
+		return
+	}
+
+	var before map[interface{}]snapshot
+	if v, ok := tx.InstanceGet(beforeKey); ok {
+		before = v.(map[interface{}]snapshot)
+	}
+	ids := primaryKeys(tx)
+	for id := range before {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	after, err := load(tx, clause.IN{Column: clause.PrimaryColumn, Values: ids})
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+
+	seen := make(map[interface{}]bool)
+	var entries []Entry
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if entry := newEntry(tx.Statement.Context, tx.Statement.Table, before[id], after[id]); entry != nil {
+			entries = append(entries, *entry)
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ResourceID < entries[j].ResourceID })
+
+	if err := tx.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+		tx.AddError(err)
+	}
+}
+
+// primaryKeys returns the non-zero primary keys of the statement's model
+func primaryKeys(tx *gorm.DB) []interface{} {
+	field := tx.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil
+	}
+
+	var ids []interface{}
+	add := func(rv reflect.Value) {
+		if id, zero := field.ValueOf(tx.Statement.Context, rv); !zero {
+			ids = append(ids, id)
+		}
+	}
+	switch rv := tx.Statement.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			add(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		add(rv)
+	}
+	return ids
+}
+
+// load snapshots the rows matching conds, including soft-deleted ones,
+// by primary key. It runs on the statement's connection so it sees the
+// transaction's own writes.
+func load(tx *gorm.DB, conds ...clause.Expression) (map[interface{}]snapshot, error) {
+	s := tx.Statement.Schema
+	rows := reflect.New(reflect.SliceOf(s.ModelType))
+	if err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Clauses(conds...).Find(rows.Interface()).Error; err != nil {
+		return nil, err
+	}
+
+	snapshots := make(map[interface{}]snapshot, rows.Elem().Len())
+	for i := 0; i < rows.Elem().Len(); i++ {
+		row := rows.Elem().Index(i)
+		id, _ := s.PrioritizedPrimaryField.ValueOf(tx.Statement.Context, row)
+		snap, err := snapshotOf(row.Interface())
+		if err != nil {
+			return nil, err
+		}
+		snapshots[id] = snap
+	}
+	return snapshots, nil
+}
+
+// PostgresStore reads entries from the audit_entries table
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a Postgres-backed store
+func NewPostgresStore(db *gorm.DB) Store {
+	return &PostgresStore{db: db}
+}
+
+// List implements Store
+func (s *PostgresStore) List(ctx context.Context, req *pagination.Request) ([]Entry, *pagination.Page, error) {
+	var entries []Entry
+	page, err := pagination.Find(s.db.WithContext(ctx).Model(&Entry{}), req, &entries)
+	return entries, page, err
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"context"
+	"example.com/production-api/internal/pagination"
+	"sync"
+)
+
+// MemoryStore keeps entries in process memory. It is fed by the
+// in-memory repositories through Record.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Record stores the change of a record in table from before to after. Its
+// signature matches repository.ChangeObserver.
+func (s *MemoryStore) Record(ctx context.Context, table string, before, after interface{}) {
+	if !audited[table] {
+		return
+	}
+	b, err := snapshotOf(before)
+	if err != nil {
+		return
+	}
+	a, err := snapshotOf(after)
+	if err != nil {
+		return
+	}
+	entry := newEntry(ctx, table, b, a)
+	if entry == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.ID = uint(len(s.entries) + 1)
+	s.entries = append(s.entries, *entry)
+}
+
+// List implements Store
+func (s *MemoryStore) List(ctx context.Context, req *pagination.Request) ([]Entry, *pagination.Page, error) {
+	s.mu.Lock()
+	entries := append([]Entry(nil), s.entries...)
+	s.mu.Unlock()
+
+	return pagination.Slice(entries, req)
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"errors"
+	"example.com/production-api/internal/apierror"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader carries API keys
+const APIKeyHeader = "X-API-Key"
+
+// Authenticate verifies the bearer token or API key of requests that
+// carry one and stores the principal in the request context. Requests
+// without credentials pass through anonymously; invalid credentials are
+// rejected instead of being silently ignored.
+func Authenticate(tokens *TokenIssuer, keys KeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			apiKey := r.Header.Get(APIKeyHeader)
+
+			switch {
+			case header == "" && apiKey == "":
+				next.ServeHTTP(w, r)
+				return
+			case header != "" && apiKey != "":
+				unauthorized(w, r, "send either a bearer token or an API key, not both", nil)
+				return
+			case apiKey != "":
+				p, err := keys.AuthenticateKey(r.Context(), apiKey)
+				if err != nil {
+					if !errors.Is(err, ErrInvalidToken) {
+						apierror.Write(w, r, apierror.Internal(err))
+						return
+					}
+					unauthorized(w, r, "API key is invalid or expired", err)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+				return
+			}
+
+			scheme, token, ok := strings.Cut(header, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") {
+				unauthorized(w, r, "authorization header must use the Bearer scheme", nil)
+				return
+			}
+
+			p, err := tokens.Parse(strings.TrimSpace(token))
+			if err != nil {
+				unauthorized(w, r, "access token is invalid or expired", err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// AuthenticateCert stores the principal of a verified client certificate
+// in the request context. A bearer token or API key on the same request
+// takes precedence and is checked by Authenticate, which must run next.
+// Certificates mapped to no user are rejected.
+func AuthenticateCert(certs CertAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 ||
+				r.Header.Get("Authorization") != "" || r.Header.Get(APIKeyHeader) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			p, err := certs.AuthenticateCert(r.Context(), r.TLS.VerifiedChains[0][0])
+			if err != nil {
+				if !errors.Is(err, ErrInvalidToken) {
+					apierror.Write(w, r, apierror.Internal(err))
+					return
+				}
+				unauthorized(w, r, "client certificate is not mapped to a user", err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// Require rejects anonymous requests with 401
+func Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if PrincipalFrom(r.Context()) == nil {
+			unauthorized(w, r, "authentication required", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, detail string, cause error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	apierror.Write(w, r, apierror.Unauthorized(detail).WithCause(cause))
+}
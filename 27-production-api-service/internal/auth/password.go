@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordMismatch is returned when a password does not match its hash
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// HashPassword returns the bcrypt hash of password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// CheckPassword compares password with hash. An empty hash still costs a
+// full bcrypt comparison, so unknown accounts can't be told apart by
+// response time.
+func CheckPassword(hash, password string) error {
+	h := []byte(hash)
+	if hash == "" {
+		dummyOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+		})
+		h = dummyHash
+	}
+
+	if err := bcrypt.CompareHashAndPassword(h, []byte(password)); err != nil || hash == "" {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"example.com/production-api/internal/config"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired or
+// not signed by a configured key
+var ErrInvalidToken = errors.New("invalid token")
+
+// minKeyLength is the shortest accepted HMAC secret in bytes
+const minKeyLength = 32
+
+// claims are the JWT claims of an access token
+type claims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// TokenIssuer signs and verifies access tokens
+type TokenIssuer struct {
+	keys        map[string][]byte
+	activeKeyID string
+	issuer      string
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+}
+
+// NewTokenIssuer creates an issuer from the auth configuration. Outside
+// development at least one signing key must be configured; in
+// development a random key is generated, so tokens don't survive a
+// restart.
+func NewTokenIssuer(cfg *config.Config, logger zerolog.Logger) (*TokenIssuer, error) {
+	ac := cfg.Auth
+	t := &TokenIssuer{
+		keys:        make(map[string][]byte, len(ac.SigningKeys)),
+		activeKeyID: ac.ActiveKeyID,
+		issuer:      ac.Issuer,
+		accessTTL:   ac.AccessTokenTTL,
+		refreshTTL:  ac.RefreshTokenTTL,
+	}
+
+	for id, secret := range ac.SigningKeys {
+		if len(secret) < minKeyLength {
+			return nil, fmt.Errorf("auth: signing key %q must be at least %d bytes", id, minKeyLength)
+		}
+		t.keys[id] = []byte(secret)
+	}
+
+	if len(t.keys) == 0 {
+		if cfg.App.Environment != "development" {
+			return nil, errors.New("auth: no signing keys configured (auth.signingkeys)")
+		}
+		secret := make([]byte, minKeyLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		t.keys["ephemeral"] = secret
+		t.activeKeyID = "ephemeral"
+		logger.Warn().Msg("No signing keys configured; using a random key, tokens will not survive a restart")
+	}
+
+	if t.activeKeyID == "" && len(t.keys) == 1 {
+		for id := range t.keys {
+			t.activeKeyID = id
+		}
+	}
+	if _, ok := t.keys[t.activeKeyID]; !ok {
+		return nil, fmt.Errorf("auth: active key %q is not one of the signing keys", t.activeKeyID)
+	}
+
+	return t, nil
+}
+
+// AccessTTL is the lifetime of access tokens
+func (t *TokenIssuer) AccessTTL() time.Duration {
+	return t.accessTTL
+}
+
+// RefreshTTL is the lifetime of refresh tokens
+func (t *TokenIssuer) RefreshTTL() time.Duration {
+	return t.refreshTTL
+}
+
+// Issue signs an access token for p with the active key
+func (t *TokenIssuer) Issue(p Principal) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    t.issuer,
+			Subject:   strconv.FormatUint(uint64(p.UserID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.accessTTL)),
+		},
+		Email: p.Email,
+		Role:  p.Role,
+	})
+	token.Header["kid"] = t.activeKeyID
+
+	return token.SignedString(t.keys[t.activeKeyID])
+}
+
+// Parse verifies an access token and returns its principal
+func (t *TokenIssuer) Parse(tokenString string) (*Principal, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(tokenString, &c, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := t.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(t.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil || id == 0 {
+		return nil, fmt.Errorf("%w: bad subject %q", ErrInvalidToken, c.Subject)
+	}
+
+	return &Principal{UserID: uint(id), Email: c.Email, Role: c.Role}, nil
+}
+
+// NewOpaqueToken returns a random URL-safe token, used for refresh tokens
+func NewOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex SHA-256 of an opaque token. Opaque tokens are
+// random, so a fast hash is enough to keep them useless if leaked from
+// the database.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"example.com/production-api/internal/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func newIssuer(t *testing.T, keys map[string]string, active string, ttl time.Duration) *TokenIssuer {
+	t.Helper()
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			SigningKeys:     keys,
+			ActiveKeyID:     active,
+			Issuer:          "test",
+			AccessTokenTTL:  ttl,
+			RefreshTokenTTL: time.Hour,
+		},
+		App: config.AppConfig{Environment: "production"},
+	}
+	tokens, err := NewTokenIssuer(cfg, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewTokenIssuer: %v", err)
+	}
+	return tokens
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	tokens := newIssuer(t, map[string]string{"a": strings.Repeat("a", 32)}, "", time.Minute)
+
+	token, err := tokens.Issue(Principal{UserID: 7, Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	p, err := tokens.Parse(token)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if p.UserID != 7 || p.Email != "alice@example.com" {
+		t.Errorf("principal = %+v; want user 7", p)
+	}
+}
+
+func TestTokenKeyRotation(t *testing.T) {
+	oldKey, newKey := strings.Repeat("o", 32), strings.Repeat("n", 32)
+	before := newIssuer(t, map[string]string{"old": oldKey}, "old", time.Minute)
+	after := newIssuer(t, map[string]string{"old": oldKey, "new": newKey}, "new", time.Minute)
+	retired := newIssuer(t, map[string]string{"new": newKey}, "new", time.Minute)
+
+	token, _ := before.Issue(Principal{UserID: 1})
+	if _, err := after.Parse(token); err != nil {
+		t.Errorf("token signed with a still-listed key rejected: %v", err)
+	}
+	if _, err := retired.Parse(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token signed with a removed key: error = %v; want ErrInvalidToken", err)
+	}
+}
+
+func TestTokenRejectsExpired(t *testing.T) {
+	tokens := newIssuer(t, map[string]string{"a": strings.Repeat("a", 32)}, "", -time.Minute)
+
+	token, _ := tokens.Issue(Principal{UserID: 1})
+	if _, err := tokens.Parse(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired token: error = %v; want ErrInvalidToken", err)
+	}
+}
+
+func TestNewTokenIssuerRequiresKeysOutsideDevelopment(t *testing.T) {
+	cfg := &config.Config{App: config.AppConfig{Environment: "production"}}
+	if _, err := NewTokenIssuer(cfg, zerolog.Nop()); err == nil {
+		t.Error("NewTokenIssuer without keys in production succeeded; want error")
+	}
+
+	cfg.Auth.SigningKeys = map[string]string{"short": "too short"}
+	if _, err := NewTokenIssuer(cfg, zerolog.Nop()); err == nil {
+		t.Error("NewTokenIssuer with a short key succeeded; want error")
+	}
+}
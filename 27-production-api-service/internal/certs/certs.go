@@ -0,0 +1,187 @@
+// Package certs serves TLS from certificate files that are reloaded when
+// they change, and generates a development CA with leaf certificates.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+
+	"example.com/production-api/internal/config"
+)
+
+// Client certificate modes accepted in server.tls.clientauth
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// debounce coalesces the events of a certificate rotation, which usually
+// replaces the certificate and the key one after the other
+const debounce = 200 * time.Millisecond
+
+// Reloader holds the server certificate and client CA pool and replaces
+// them when their files change. Handshakes always see a consistent
+// certificate and key.
+type Reloader struct {
+	cfg    config.TLSConfig
+	logger zerolog.Logger
+
+	cert atomic.Pointer[tls.Certificate]
+	pool atomic.Pointer[x509.CertPool]
+}
+
+// NewReloader loads the files named in cfg
+func NewReloader(cfg config.TLSConfig, logger zerolog.Logger) (*Reloader, error) {
+	r := &Reloader{cfg: cfg, logger: logger.With().Str("component", "tls").Logger()}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate, key and client CA bundle again. On error
+// the previous ones stay in use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("failed to parse TLS certificate: %w", err)
+		}
+	}
+
+	var pool *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		data, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.New("client CA bundle " + r.cfg.ClientCAFile + " contains no certificates")
+		}
+	}
+
+	r.cert.Store(&cert)
+	r.pool.Store(pool)
+	r.logger.Info().
+		Str("subject", cert.Leaf.Subject.String()).
+		Time("not_after", cert.Leaf.NotAfter).
+		Msg("TLS certificate loaded")
+	return nil
+}
+
+// TLSConfig returns the server configuration. Certificates and client CAs
+// are looked up per handshake, so reloads apply to new connections.
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.cert.Load(), nil
+		},
+	}
+	if r.cfg.MinVersion == "1.3" {
+		base.MinVersion = tls.VersionTLS13
+	}
+
+	switch r.cfg.ClientAuth {
+	case ClientAuthOptional:
+		base.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return base
+	}
+
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientCAs = r.pool.Load()
+		return cfg, nil
+	}
+	return base
+}
+
+// Watch reloads the files whenever one of them changes until done is
+// closed. Directories are watched rather than files because rotation
+// tools and Kubernetes replace files instead of writing to them.
+func (r *Reloader) Watch(done <-chan struct{}) error {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch TLS files: %w", err)
+	}
+
+	files := map[string]string{}
+	for _, f := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			fs.Close()
+			return err
+		}
+		files[abs], _ = filepath.EvalSymlinks(abs)
+		if err := fs.Add(filepath.Dir(abs)); err != nil {
+			fs.Close()
+			return fmt.Errorf("failed to watch TLS files: %w", err)
+		}
+	}
+
+	go func() {
+		defer fs.Close()
+		errs := fs.Errors
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case ev, ok := <-fs.Events:
+				if !ok {
+					return
+				}
+				if changed(files, ev.Name) {
+					timer = time.After(debounce)
+				}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				r.logger.Error().Err(err).Msg("TLS watch error")
+			case <-timer:
+				timer = nil
+				if err := r.Reload(); err != nil {
+					r.logger.Error().Err(err).Msg("TLS reload failed, keeping the current certificate")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// changed reports whether an event on name affects one of files, either
+// directly or by swapping the target of a symlink
+func changed(files map[string]string, name string) bool {
+	hit := false
+	for file, target := range files {
+		current, _ := filepath.EvalSymlinks(file)
+		if filepath.Clean(name) == file || current != target {
+			files[file] = current
+			hit = true
+		}
+	}
+	return hit
+}
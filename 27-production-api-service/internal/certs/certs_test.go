@@ -0,0 +1,182 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"example.com/production-api/internal/config"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// fixture is a CA with a server certificate and one client certificate
+// written to a temporary directory
+type fixture struct {
+	ca         *Authority
+	dir        string
+	clientCert tls.Certificate
+	roots      *x509.CertPool
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ca, err := NewAuthority("test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fixture{ca: ca, dir: t.TempDir(), roots: x509.NewCertPool()}
+	f.roots.AddCert(ca.Cert)
+
+	caPEM, _, err := ca.PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(f.dir, "ca.pem"), caPEM, 0o600)
+	f.issueServer(t, "localhost")
+
+	certPEM, keyPEM, err := ca.IssueClient("billing", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.clientCert, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// issueServer replaces the server certificate the way rotation tools do
+func (f *fixture) issueServer(t *testing.T, host string) {
+	t.Helper()
+	certPEM, keyPEM, err := f.ca.IssueServer([]string{host, "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"server.pem": certPEM, "server-key.pem": keyPEM} {
+		tmp := filepath.Join(f.dir, name+".tmp")
+		os.WriteFile(tmp, data, 0o600)
+		if err := os.Rename(tmp, filepath.Join(f.dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (f *fixture) config(clientAuth string) config.TLSConfig {
+	return config.TLSConfig{
+		CertFile:     filepath.Join(f.dir, "server.pem"),
+		KeyFile:      filepath.Join(f.dir, "server-key.pem"),
+		MinVersion:   "1.2",
+		ClientAuth:   clientAuth,
+		ClientCAFile: filepath.Join(f.dir, "ca.pem"),
+	}
+}
+
+// handshake connects to a TLS listener using r and returns what each side
+// saw: the server's common name and the verified client chains
+func handshake(t *testing.T, r *Reloader, f *fixture, withClientCert bool) (string, [][]*x509.Certificate, error) {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	chains := make(chan [][]*x509.Certificate, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			chains <- nil
+			return
+		}
+		defer conn.Close()
+		tc := conn.(*tls.Conn)
+		tc.Handshake()
+		chains <- tc.ConnectionState().VerifiedChains
+		io.Copy(io.Discard, tc)
+	}()
+
+	cfg := &tls.Config{RootCAs: f.roots, ServerName: "127.0.0.1"}
+	if withClientCert {
+		cfg.Certificates = []tls.Certificate{f.clientCert}
+	}
+	conn, err := tls.Dial("tcp", ln.Addr().String(), cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	defer conn.Close()
+
+	// TLS 1.3 reports a rejected client certificate on the first read
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			return "", nil, err
+		}
+	}
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, <-chains, nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	f := newFixture(t)
+	r, err := NewReloader(f.config(ClientAuthRequire), zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cn, chains, err := handshake(t, r, f, true)
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	if cn != "localhost" || len(chains) == 0 || chains[0][0].Subject.CommonName != "billing" {
+		t.Errorf("server %q, client chains %v; want localhost and billing", cn, chains)
+	}
+
+	if _, _, err := handshake(t, r, f, false); err == nil {
+		t.Error("handshake without client certificate succeeded; want rejection")
+	}
+}
+
+func TestWatchReloadsCertificate(t *testing.T) {
+	f := newFixture(t)
+	r, err := NewReloader(f.config(ClientAuthNone), zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	if err := r.Watch(done); err != nil {
+		t.Fatal(err)
+	}
+
+	f.issueServer(t, "rotated.localhost")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for r.cert.Load().Leaf.Subject.CommonName != "rotated.localhost" {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded after the files changed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if cn, _, err := handshake(t, r, f, false); err != nil || cn != "rotated.localhost" {
+		t.Errorf("after rotation: server %q, err %v; want rotated.localhost", cn, err)
+	}
+}
+
+func TestReloadKeepsCertificateOnError(t *testing.T) {
+	f := newFixture(t)
+	r, err := NewReloader(f.config(ClientAuthNone), zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(f.dir, "server-key.pem"), []byte("garbage"), 0o600)
+	if err := r.Reload(); err == nil {
+		t.Fatal("reload with a broken key succeeded")
+	}
+	if cn, _, err := handshake(t, r, f, false); err != nil || cn != "localhost" {
+		t.Errorf("after failed reload: server %q, err %v; want the old certificate", cn, err)
+	}
+}
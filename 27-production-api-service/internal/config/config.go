@@ -2,35 +2,223 @@
 package config
 
 import (
-	"github.com/spf13/viper"
-	"go.uber.org/fx"
-)
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
-// Module provides configuration dependencies
-var Module = fx.Options(
-	fx.Provide(New),
+	"github.com/spf13/viper"
 )
 
 // Config holds all application configuration
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	App      AppConfig
+	// File is the config file that was read, empty if none was found
+	File string
+
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Auth        AuthConfig
+	RateLimit   RateLimitConfig
+	Idempotency IdempotencyConfig
+	Retention   RetentionConfig
+	Metrics     MetricsConfig
+	Tracing     TracingConfig
+	Health      HealthConfig
+	CORS        CORSConfig
+	App         AppConfig
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
 	Port string
+
+	// ReadTimeout bounds reading a whole request, body included, and
+	// ReadHeaderTimeout the headers alone
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout bounds the time from the end of the request headers to
+	// the end of the response
+	WriteTimeout time.Duration
+	// IdleTimeout closes keep-alive connections idle for longer
+	IdleTimeout    time.Duration
+	MaxHeaderBytes int
+
+	// MaxBodyBytes caps request bodies. BodyLimits overrides it per route
+	// group: auth, users, posts, admin.
+	MaxBodyBytes int64
+	BodyLimits   map[string]int64
+
+	// ShutdownTimeout bounds waiting for in-flight requests on shutdown;
+	// connections still open afterwards are closed
+	ShutdownTimeout time.Duration
+
+	TLS TLSConfig
+}
+
+// TLSConfig enables HTTPS and client certificate authentication
+type TLSConfig struct {
+	// CertFile and KeyFile hold the PEM certificate chain and key. Empty
+	// serves plain HTTP. Both are reloaded when they change.
+	CertFile string
+	KeyFile  string
+	// MinVersion is "1.2" or "1.3"
+	MinVersion string
+
+	// ClientAuth is "none", "optional" or "require". Client certificates
+	// are verified against the PEM bundle in ClientCAFile.
+	ClientAuth   string
+	ClientCAFile string
+	// ClientPrincipals maps verified client certificates to the users
+	// they act as
+	ClientPrincipals []ClientPrincipal
+}
+
+// ClientPrincipal maps a client certificate subject to a user
+type ClientPrincipal struct {
+	// Subject matches the certificate's common name or its full subject,
+	// e.g. "CN=billing,O=Example"
+	Subject string
+	// Email identifies the user the caller acts as
+	Email string
 }
 
 // DatabaseConfig holds database connection configuration
 type DatabaseConfig struct {
+	// Driver selects the repository implementation: "postgres" or "memory"
+	Driver string
+
 	Host     string
 	Port     int
 	User     string
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// Replicas serve reads outside transactions. They share the
+	// primary's user, password, database name and SSL mode.
+	Replicas []ReplicaConfig
+
+	// Pool settings apply to the primary and to each replica
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectBackoff is the first wait between connection attempts at
+	// startup. It doubles after every failed attempt up to
+	// ConnectMaxBackoff; attempts stop at app.starttimeout.
+	ConnectBackoff    time.Duration
+	ConnectMaxBackoff time.Duration
+
+	// SlowQueryThreshold marks queries slower than this as warnings in the log
+	SlowQueryThreshold time.Duration
+
+	// AutoMigrate applies pending migrations on startup
+	AutoMigrate bool
+}
+
+// ReplicaConfig is the address of a read replica
+type ReplicaConfig struct {
+	Host string
+	Port int
+}
+
+// AuthConfig holds token signing configuration
+type AuthConfig struct {
+	// SigningKeys maps key IDs to HMAC secrets. Every key is accepted
+	// when verifying, so old keys can stay listed while tokens signed
+	// with them expire.
+	SigningKeys map[string]string
+	// ActiveKeyID selects the key new tokens are signed with. It may be
+	// left empty when there is only one key.
+	ActiveKeyID string
+
+	Issuer          string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// RateLimitConfig holds the token-bucket rate limits
+type RateLimitConfig struct {
+	Enabled bool
+	// Store keeps the buckets: "memory" limits each replica on its own,
+	// "postgres" shares limits between replicas
+	Store string
+	// Default applies to route groups without their own rule
+	Default RateLimitRule
+	// Groups overrides the rule per route group: auth, users, posts, admin
+	Groups map[string]RateLimitRule
+}
+
+// RateLimitRule is one token bucket: Requests tokens are added every Per,
+// up to Burst
+type RateLimitRule struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+	// KeyBy is "ip" or "principal". Anonymous callers are always keyed
+	// by IP.
+	KeyBy string
+}
+
+// IdempotencyConfig controls how long Idempotency-Key responses are kept
+type IdempotencyConfig struct {
+	// TTL is how long a stored response can be replayed
+	TTL time.Duration
+	// CleanupInterval is how often expired records are deleted
+	CleanupInterval time.Duration
+}
+
+// RetentionConfig controls how long soft-deleted users and posts are kept
+type RetentionConfig struct {
+	// Window is how long deleted records can be restored before they are
+	// purged for good. Zero keeps them forever.
+	Window time.Duration
+	// PurgeInterval is how often expired records are purged
+	PurgeInterval time.Duration
+}
+
+// MetricsConfig controls the Prometheus admin listener
+type MetricsConfig struct {
+	Enabled bool
+	// Port serves /metrics, apart from the public API
+	Port string
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported
+type TracingConfig struct {
+	// Exporter is "none", "stdout" or "otlpfile". With "none" incoming
+	// trace context is still propagated and logged.
+	Exporter string
+	// File is where "otlpfile" appends spans as OTLP/JSON, one batch per
+	// line
+	File string
+	// SampleRatio is the share of new traces that are recorded. Requests
+	// continuing a caller's trace follow the caller's decision.
+	SampleRatio float64
+}
+
+// HealthConfig tunes the health probes
+type HealthConfig struct {
+	// Timeout bounds each readiness check
+	Timeout time.Duration
+	// DrainDelay is how long the server keeps serving after reporting
+	// not-ready on shutdown, so load balancers stop sending traffic first
+	DrainDelay time.Duration
+}
+
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	// AllowedOrigins lists origins such as "https://app.example.com", or
+	// "*" for any. Empty disables CORS.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
 }
 
 // AppConfig holds application metadata
@@ -38,55 +226,324 @@ type AppConfig struct {
 	Name        string
 	Environment string
 	LogLevel    string
+
+	// StartTimeout bounds startup, including waiting for the database
+	StartTimeout time.Duration
 }
 
-// New creates and initializes configuration
-func New() (*Config, error) {
+// envPrefix prefixes every environment variable, e.g. APP_DATABASE_HOST
+// for database.host
+const envPrefix = "APP"
+
+// fileSuffix marks environment variables naming a file that holds the
+// value, e.g. APP_DATABASE_PASSWORD_FILE=/run/secrets/db-password
+const fileSuffix = "_FILE"
+
+// New loads, validates and returns the configuration. path names the
+// config file; when empty config.yaml is looked up in . and ./config and
+// may be absent. It is called before the fx application is built because
+// some modules are selected from config.
+func New(path string) (*Config, error) {
 	v := viper.New()
 
 	// Defaults
 	v.SetDefault("server.port", "8080")
+	v.SetDefault("server.readtimeout", 15*time.Second)
+	v.SetDefault("server.readheadertimeout", 5*time.Second)
+	v.SetDefault("server.writetimeout", 30*time.Second)
+	v.SetDefault("server.idletimeout", 2*time.Minute)
+	v.SetDefault("server.maxheaderbytes", 1<<20)
+	v.SetDefault("server.maxbodybytes", 1<<20)
+	v.SetDefault("server.shutdowntimeout", 15*time.Second)
+	v.SetDefault("server.tls.certfile", "")
+	v.SetDefault("server.tls.keyfile", "")
+	v.SetDefault("server.tls.minversion", "1.2")
+	v.SetDefault("server.tls.clientauth", "none")
+	v.SetDefault("server.tls.clientcafile", "")
+	v.SetDefault("database.driver", "postgres")
 	v.SetDefault("database.host", "localhost")
 	v.SetDefault("database.port", 5432)
 	v.SetDefault("database.user", "postgres")
 	v.SetDefault("database.password", "postgres")
 	v.SetDefault("database.dbname", "tutorial")
 	v.SetDefault("database.sslmode", "disable")
+	v.SetDefault("database.maxopenconns", 25)
+	v.SetDefault("database.maxidleconns", 10)
+	v.SetDefault("database.connmaxlifetime", 30*time.Minute)
+	v.SetDefault("database.connmaxidletime", 5*time.Minute)
+	v.SetDefault("database.connectbackoff", 250*time.Millisecond)
+	v.SetDefault("database.connectmaxbackoff", 5*time.Second)
+	v.SetDefault("database.slowquerythreshold", 200*time.Millisecond)
+	v.SetDefault("database.automigrate", false)
+	v.SetDefault("auth.issuer", "production-api")
+	v.SetDefault("auth.accesstokenttl", 15*time.Minute)
+	v.SetDefault("auth.refreshtokenttl", 30*24*time.Hour)
+	v.SetDefault("ratelimit.enabled", true)
+	v.SetDefault("ratelimit.store", "memory")
+	v.SetDefault("ratelimit.default.requests", 300)
+	v.SetDefault("ratelimit.default.per", time.Minute)
+	v.SetDefault("ratelimit.default.burst", 60)
+	v.SetDefault("ratelimit.default.keyby", "principal")
+	v.SetDefault("idempotency.ttl", 24*time.Hour)
+	v.SetDefault("idempotency.cleanupinterval", time.Hour)
+	v.SetDefault("retention.window", 30*24*time.Hour)
+	v.SetDefault("retention.purgeinterval", time.Hour)
+	v.SetDefault("metrics.enabled", true)
+	v.SetDefault("metrics.port", "9090")
+	v.SetDefault("tracing.exporter", "none")
+	v.SetDefault("tracing.file", "traces.jsonl")
+	v.SetDefault("tracing.sampleratio", 1.0)
+	v.SetDefault("health.timeout", 2*time.Second)
+	v.SetDefault("health.draindelay", 5*time.Second)
+	v.SetDefault("cors.allowedorigins", []string{})
+	v.SetDefault("cors.allowedmethods", []string{"GET", "POST", "PUT", "PATCH", "DELETE"})
+	v.SetDefault("cors.allowedheaders", []string{"Authorization", "Content-Type", "Idempotency-Key", "If-Match", "If-None-Match", "X-API-Key"})
+	v.SetDefault("cors.exposedheaders", []string{"ETag", "Link", "Retry-After", "X-Next-Cursor", "X-Total-Count", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"})
+	v.SetDefault("cors.allowcredentials", false)
+	v.SetDefault("cors.maxage", 10*time.Minute)
 	v.SetDefault("app.name", "Production API")
 	v.SetDefault("app.environment", "development")
 	v.SetDefault("app.loglevel", "info")
+	v.SetDefault("app.starttimeout", 30*time.Second)
 
-	// Environment variables
-	v.SetEnvPrefix("APP")
+	// Environment variables override nested keys with dots as
+	// underscores
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
 	// Config file
-	v.SetConfigName("config")
-	v.SetConfigType("yaml")
-	v.AddConfigPath(".")
-	v.AddConfigPath("./config")
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath(".")
+		v.AddConfigPath("./config")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if path != "" || !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	if err := readSecretFiles(v); err != nil {
+		return nil, err
+	}
+
+	config, err := fromViper(v)
+	if err != nil {
+		return nil, err
+	}
+	config.File = v.ConfigFileUsed()
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// secretMaps are map settings whose entries may be read from files.
+// Entries have no defaults, so they are found by scanning the
+// environment: APP_AUTH_SIGNINGKEYS_2024_FILE sets auth.signingkeys.2024.
+var secretMaps = []string{"auth.signingkeys"}
+
+// readSecretFiles sets every setting whose <VAR>_FILE variable is set to
+// the contents of that file, without a trailing newline. Setting both the
+// variable and its _FILE variant is an error.
+func readSecretFiles(v *viper.Viper) error {
+	keys := make(map[string]string)
+	for _, key := range v.AllKeys() {
+		keys[envName(key)] = key
+	}
+
+	for _, kv := range os.Environ() {
+		name, path, _ := strings.Cut(kv, "=")
+		env, ok := strings.CutSuffix(name, fileSuffix)
+		if !ok || !strings.HasPrefix(env, envPrefix+"_") {
+			continue
+		}
+
+		// Map entries are merged into the map: setting one entry on its
+		// own would hide the others
+		mapKey, entry := "", ""
+		for _, m := range secretMaps {
+			if e, ok := strings.CutPrefix(env, envName(m)+"_"); ok && e != "" {
+				mapKey, entry = m, strings.ToLower(e)
+			}
+		}
+		key, known := keys[env]
+		if mapKey == "" && !known {
+			continue
+		}
+
+		if _, ok := os.LookupEnv(env); ok {
+			return fmt.Errorf("both %s and %s are set", env, name)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		value := strings.TrimRight(string(data), "\r\n")
+
+		if mapKey == "" {
+			v.Set(key, value)
+			continue
+		}
+		entries := make(map[string]interface{})
+		for k, val := range v.GetStringMap(mapKey) {
+			entries[k] = val
+		}
+		entries[entry] = value
+		v.Set(mapKey, entries)
+	}
+	return nil
+}
+
+// envName is the environment variable overriding key
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// fromViper builds the Config from the merged settings in v
+func fromViper(v *viper.Viper) (*Config, error) {
+	var bodyLimits map[string]int64
+	if err := v.UnmarshalKey("server.bodylimits", &bodyLimits); err != nil {
+		return nil, fmt.Errorf("server.bodylimits: %w", err)
+	}
 
-	// Read config (optional)
-	v.ReadInConfig()
+	var replicas []ReplicaConfig
+	if err := v.UnmarshalKey("database.replicas", &replicas); err != nil {
+		return nil, fmt.Errorf("database.replicas: %w", err)
+	}
+
+	var clientPrincipals []ClientPrincipal
+	if err := v.UnmarshalKey("server.tls.clientprincipals", &clientPrincipals); err != nil {
+		return nil, fmt.Errorf("server.tls.clientprincipals: %w", err)
+	}
+
+	var rateLimitGroups map[string]RateLimitRule
+	if err := v.UnmarshalKey("ratelimit.groups", &rateLimitGroups); err != nil {
+		return nil, fmt.Errorf("ratelimit.groups: %w", err)
+	}
 
 	config := &Config{
 		Server: ServerConfig{
-			Port: v.GetString("server.port"),
+			Port:              v.GetString("server.port"),
+			ReadTimeout:       v.GetDuration("server.readtimeout"),
+			ReadHeaderTimeout: v.GetDuration("server.readheadertimeout"),
+			WriteTimeout:      v.GetDuration("server.writetimeout"),
+			IdleTimeout:       v.GetDuration("server.idletimeout"),
+			MaxHeaderBytes:    v.GetInt("server.maxheaderbytes"),
+			MaxBodyBytes:      v.GetInt64("server.maxbodybytes"),
+			BodyLimits:        bodyLimits,
+			ShutdownTimeout:   v.GetDuration("server.shutdowntimeout"),
+			TLS: TLSConfig{
+				CertFile:         v.GetString("server.tls.certfile"),
+				KeyFile:          v.GetString("server.tls.keyfile"),
+				MinVersion:       v.GetString("server.tls.minversion"),
+				ClientAuth:       v.GetString("server.tls.clientauth"),
+				ClientCAFile:     v.GetString("server.tls.clientcafile"),
+				ClientPrincipals: clientPrincipals,
+			},
 		},
 		Database: DatabaseConfig{
+			Driver:   v.GetString("database.driver"),
 			Host:     v.GetString("database.host"),
 			Port:     v.GetInt("database.port"),
 			User:     v.GetString("database.user"),
 			Password: v.GetString("database.password"),
 			DBName:   v.GetString("database.dbname"),
 			SSLMode:  v.GetString("database.sslmode"),
+			Replicas: replicas,
+
+			MaxOpenConns:    v.GetInt("database.maxopenconns"),
+			MaxIdleConns:    v.GetInt("database.maxidleconns"),
+			ConnMaxLifetime: v.GetDuration("database.connmaxlifetime"),
+			ConnMaxIdleTime: v.GetDuration("database.connmaxidletime"),
+
+			ConnectBackoff:    v.GetDuration("database.connectbackoff"),
+			ConnectMaxBackoff: v.GetDuration("database.connectmaxbackoff"),
+
+			SlowQueryThreshold: v.GetDuration("database.slowquerythreshold"),
+			AutoMigrate:        v.GetBool("database.automigrate"),
+		},
+		Auth: AuthConfig{
+			SigningKeys:     v.GetStringMapString("auth.signingkeys"),
+			ActiveKeyID:     v.GetString("auth.activekeyid"),
+			Issuer:          v.GetString("auth.issuer"),
+			AccessTokenTTL:  v.GetDuration("auth.accesstokenttl"),
+			RefreshTokenTTL: v.GetDuration("auth.refreshtokenttl"),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: v.GetBool("ratelimit.enabled"),
+			Store:   v.GetString("ratelimit.store"),
+			Default: RateLimitRule{
+				Requests: v.GetInt("ratelimit.default.requests"),
+				Per:      v.GetDuration("ratelimit.default.per"),
+				Burst:    v.GetInt("ratelimit.default.burst"),
+				KeyBy:    v.GetString("ratelimit.default.keyby"),
+			},
+			Groups: rateLimitGroups,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:             v.GetDuration("idempotency.ttl"),
+			CleanupInterval: v.GetDuration("idempotency.cleanupinterval"),
+		},
+		Retention: RetentionConfig{
+			Window:        v.GetDuration("retention.window"),
+			PurgeInterval: v.GetDuration("retention.purgeinterval"),
+		},
+		Metrics: MetricsConfig{
+			Enabled: v.GetBool("metrics.enabled"),
+			Port:    v.GetString("metrics.port"),
+		},
+		Tracing: TracingConfig{
+			Exporter:    v.GetString("tracing.exporter"),
+			File:        v.GetString("tracing.file"),
+			SampleRatio: v.GetFloat64("tracing.sampleratio"),
+		},
+		Health: HealthConfig{
+			Timeout:    v.GetDuration("health.timeout"),
+			DrainDelay: v.GetDuration("health.draindelay"),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   v.GetStringSlice("cors.allowedorigins"),
+			AllowedMethods:   v.GetStringSlice("cors.allowedmethods"),
+			AllowedHeaders:   v.GetStringSlice("cors.allowedheaders"),
+			ExposedHeaders:   v.GetStringSlice("cors.exposedheaders"),
+			AllowCredentials: v.GetBool("cors.allowcredentials"),
+			MaxAge:           v.GetDuration("cors.maxage"),
 		},
 		App: AppConfig{
 			Name:        v.GetString("app.name"),
 			Environment: v.GetString("app.environment"),
 			LogLevel:    v.GetString("app.loglevel"),
+
+			StartTimeout: v.GetDuration("app.starttimeout"),
 		},
 	}
 
 	return config, nil
 }
+
+// redacted replaces secret values in config dumps
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the configuration that is safe to print or
+// log: secrets are replaced with a placeholder
+func (c *Config) Redacted() Config {
+	cp := *c
+	if cp.Database.Password != "" {
+		cp.Database.Password = redacted
+	}
+	if len(cp.Auth.SigningKeys) > 0 {
+		keys := make(map[string]string, len(cp.Auth.SigningKeys))
+		for id := range cp.Auth.SigningKeys {
+			keys[id] = redacted
+		}
+		cp.Auth.SigningKeys = keys
+	}
+	return cp
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultsAreValid(t *testing.T) {
+	c, err := New("")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if c.File != "" || c.Server.Port != "8080" || c.Database.Port != 5432 {
+		t.Errorf("config = %+v; want defaults without a file", c)
+	}
+}
+
+func TestFileAndNestedEnv(t *testing.T) {
+	path := writeFile(t, "api.yaml", "database:\n  host: db.internal\n  user: api\n")
+	t.Setenv("APP_DATABASE_HOST", "replica.internal")
+	t.Setenv("APP_RATELIMIT_DEFAULT_BURST", "7")
+
+	c, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if c.File != path {
+		t.Errorf("File = %q; want %q", c.File, path)
+	}
+	if c.Database.Host != "replica.internal" || c.Database.User != "api" {
+		t.Errorf("database = %+v; want host from env and user from file", c.Database)
+	}
+	if c.RateLimit.Default.Burst != 7 {
+		t.Errorf("burst = %d; want 7", c.RateLimit.Default.Burst)
+	}
+}
+
+func TestSecretFile(t *testing.T) {
+	t.Setenv("APP_DATABASE_PASSWORD_FILE", writeFile(t, "password", "s3cr3t 'quoted'\n"))
+
+	c, err := New("")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if c.Database.Password != "s3cr3t 'quoted'" {
+		t.Errorf("password = %q", c.Database.Password)
+	}
+	if got := c.Redacted().Database.Password; got != redacted {
+		t.Errorf("redacted password = %q", got)
+	}
+
+	t.Setenv("APP_DATABASE_PASSWORD", "plain")
+	if _, err := New(""); err == nil || !strings.Contains(err.Error(), "both") {
+		t.Errorf("both set: err = %v; want conflict", err)
+	}
+}
+
+func TestSecretFileMapEntries(t *testing.T) {
+	path := writeFile(t, "api.yaml", "auth:\n  activekeyid: current\n  signingkeys:\n    current: from-config\n    old: kept\n")
+	t.Setenv("APP_AUTH_SIGNINGKEYS_CURRENT_FILE", writeFile(t, "current", "from-file\n"))
+	t.Setenv("APP_AUTH_SIGNINGKEYS_NEXT_FILE", writeFile(t, "next", "added\n"))
+
+	c, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	want := map[string]string{"current": "from-file", "old": "kept", "next": "added"}
+	if !reflect.DeepEqual(c.Auth.SigningKeys, want) {
+		t.Errorf("signing keys = %q; want %q", c.Auth.SigningKeys, want)
+	}
+
+	t.Setenv("APP_AUTH_SIGNINGKEYS_NEXT", "plain")
+	if _, err := New(path); err == nil || !strings.Contains(err.Error(), "both") {
+		t.Errorf("both set: err = %v; want conflict", err)
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("missing --config file: want error")
+	}
+	if _, err := New(writeFile(t, "bad.yaml", "server: [\n")); err == nil {
+		t.Error("malformed file: want error")
+	}
+}
+
+func TestValidationAggregates(t *testing.T) {
+	t.Setenv("APP_SERVER_PORT", "70000")
+	t.Setenv("APP_DATABASE_SSLMODE", "sometimes")
+	t.Setenv("APP_DATABASE_HOST", " ")
+	t.Setenv("APP_DATABASE_CONNECTMAXBACKOFF", "100ms")
+	t.Setenv("APP_TRACING_SAMPLERATIO", "2")
+
+	_, err := New("")
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v; want *ValidationError", err)
+	}
+	want := []string{"server.port", "database.host", "database.sslmode", "database.connectmaxbackoff", "tracing.sampleratio"}
+	if len(verr.Problems) != len(want) {
+		t.Fatalf("problems = %q; want %d", verr.Problems, len(want))
+	}
+	for i, key := range want {
+		if !strings.HasPrefix(verr.Problems[i], key+": ") {
+			t.Errorf("problem %d = %q; want %s", i, verr.Problems[i], key)
+		}
+	}
+}
+
+func TestActiveKeyID(t *testing.T) {
+	t.Setenv("APP_AUTH_SIGNINGKEYS_ONLY_FILE", writeFile(t, "only", "secret"))
+	if _, err := New(""); err != nil {
+		t.Errorf("single key without activekeyid: %v", err)
+	}
+
+	t.Setenv("APP_AUTH_SIGNINGKEYS_OTHER_FILE", writeFile(t, "other", "secret"))
+	if _, err := New(""); err == nil || !strings.Contains(err.Error(), "auth.activekeyid") {
+		t.Errorf("two keys without activekeyid: err = %v; want auth.activekeyid problem", err)
+	}
+}
+
+func TestValidateMemoryDriverSkipsDatabase(t *testing.T) {
+	t.Setenv("APP_DATABASE_DRIVER", "memory")
+	t.Setenv("APP_DATABASE_SSLMODE", "sometimes")
+
+	if _, err := New(""); err != nil {
+		t.Errorf("New: %v", err)
+	}
+}
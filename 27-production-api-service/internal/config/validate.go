@@ -0,0 +1,239 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Accepted values of enumerated settings. The packages that interpret them
+// import config, so the literals are repeated here.
+var (
+	drivers       = []string{"postgres", "memory"}
+	sslModes      = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	rateLimitKeys = []string{"ip", "principal"}
+	limiterStores = []string{"memory", "postgres"}
+	tlsVersions   = []string{"1.2", "1.3"}
+	clientAuths   = []string{"none", "optional", "require"}
+	exporters     = []string{"none", "stdout", "otlpfile"}
+	logLevels     = []string{"trace", "debug", "info", "warn", "error", "fatal", "panic"}
+)
+
+// ValidationError lists every invalid setting found by Validate
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// problems collects validation failures keyed by setting
+type problems []string
+
+func (p *problems) addf(key, format string, args ...interface{}) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (p *problems) required(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		p.addf(key, "is required")
+	}
+}
+
+func (p *problems) oneOf(key, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	p.addf(key, "%q is not one of %s", value, strings.Join(allowed, ", "))
+}
+
+func (p *problems) port(key string, value int) {
+	if value < 1 || value > 65535 {
+		p.addf(key, "%d is not a port between 1 and 65535", value)
+	}
+}
+
+func (p *problems) portString(key, value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		p.addf(key, "%q is not a port number", value)
+		return
+	}
+	p.port(key, n)
+}
+
+func (p *problems) positive(key string, d time.Duration) {
+	if d <= 0 {
+		p.addf(key, "must be positive, got %s", d)
+	}
+}
+
+func (p *problems) nonNegative(key string, d time.Duration) {
+	if d < 0 {
+		p.addf(key, "must not be negative, got %s", d)
+	}
+}
+
+func (p *problems) bytes(key string, n int64) {
+	if n <= 0 {
+		p.addf(key, "must be a positive number of bytes, got %d", n)
+	}
+}
+
+func (p *problems) tls(key string, t TLSConfig) {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		p.addf(key, "certfile and keyfile must be set together")
+	}
+	p.oneOf(key+".minversion", t.MinVersion, tlsVersions)
+	p.oneOf(key+".clientauth", t.ClientAuth, clientAuths)
+	if t.ClientAuth != "none" {
+		if t.CertFile == "" {
+			p.addf(key+".clientauth", "needs certfile and keyfile")
+		}
+		p.required(key+".clientcafile", t.ClientCAFile)
+	}
+	for i, cp := range t.ClientPrincipals {
+		p.required(fmt.Sprintf("%s.clientprincipals[%d].subject", key, i), cp.Subject)
+		p.required(fmt.Sprintf("%s.clientprincipals[%d].email", key, i), cp.Email)
+	}
+}
+
+func (p *problems) rule(key string, r RateLimitRule) {
+	if r.Requests <= 0 {
+		p.addf(key+".requests", "must be positive, got %d", r.Requests)
+	}
+	p.positive(key+".per", r.Per)
+	if r.Burst < 0 {
+		p.addf(key+".burst", "must not be negative, got %d", r.Burst)
+	}
+	p.oneOf(key+".keyby", r.KeyBy, rateLimitKeys)
+}
+
+// sortedKeys returns the keys of m in order, so problems are reported in
+// a stable order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Validate checks every setting and reports all problems at once as a
+// *ValidationError. Checks that need other packages, such as the length of
+// signing keys, are left to those packages.
+func (c *Config) Validate() error {
+	var p problems
+
+	p.portString("server.port", c.Server.Port)
+	p.nonNegative("server.readtimeout", c.Server.ReadTimeout)
+	p.nonNegative("server.readheadertimeout", c.Server.ReadHeaderTimeout)
+	p.nonNegative("server.writetimeout", c.Server.WriteTimeout)
+	p.nonNegative("server.idletimeout", c.Server.IdleTimeout)
+	if c.Server.MaxHeaderBytes <= 0 {
+		p.addf("server.maxheaderbytes", "must be positive, got %d", c.Server.MaxHeaderBytes)
+	}
+	p.bytes("server.maxbodybytes", c.Server.MaxBodyBytes)
+	for _, group := range sortedKeys(c.Server.BodyLimits) {
+		p.bytes("server.bodylimits."+group, c.Server.BodyLimits[group])
+	}
+	p.positive("server.shutdowntimeout", c.Server.ShutdownTimeout)
+	p.tls("server.tls", c.Server.TLS)
+
+	p.oneOf("database.driver", c.Database.Driver, drivers)
+	if c.Database.Driver == "postgres" {
+		p.required("database.host", c.Database.Host)
+		p.port("database.port", c.Database.Port)
+		p.required("database.user", c.Database.User)
+		p.required("database.dbname", c.Database.DBName)
+		p.oneOf("database.sslmode", c.Database.SSLMode, sslModes)
+		for i, r := range c.Database.Replicas {
+			key := fmt.Sprintf("database.replicas[%d]", i)
+			p.required(key+".host", r.Host)
+			p.port(key+".port", r.Port)
+		}
+	}
+	if c.Database.MaxOpenConns < 0 {
+		p.addf("database.maxopenconns", "must not be negative, got %d", c.Database.MaxOpenConns)
+	}
+	if c.Database.MaxIdleConns < 0 {
+		p.addf("database.maxidleconns", "must not be negative, got %d", c.Database.MaxIdleConns)
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		p.addf("database.maxidleconns", "%d exceeds database.maxopenconns %d", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	}
+	p.nonNegative("database.connmaxlifetime", c.Database.ConnMaxLifetime)
+	p.nonNegative("database.connmaxidletime", c.Database.ConnMaxIdleTime)
+	p.positive("database.connectbackoff", c.Database.ConnectBackoff)
+	if c.Database.ConnectMaxBackoff < c.Database.ConnectBackoff {
+		p.addf("database.connectmaxbackoff", "%s is below database.connectbackoff %s", c.Database.ConnectMaxBackoff, c.Database.ConnectBackoff)
+	}
+	p.nonNegative("database.slowquerythreshold", c.Database.SlowQueryThreshold)
+
+	if c.Auth.ActiveKeyID != "" {
+		if _, ok := c.Auth.SigningKeys[c.Auth.ActiveKeyID]; !ok {
+			p.addf("auth.activekeyid", "%q is not in auth.signingkeys", c.Auth.ActiveKeyID)
+		}
+	} else if len(c.Auth.SigningKeys) > 1 {
+		// A single key is active without being named
+		p.addf("auth.activekeyid", "is required when auth.signingkeys has more than one key")
+	}
+	p.required("auth.issuer", c.Auth.Issuer)
+	p.positive("auth.accesstokenttl", c.Auth.AccessTokenTTL)
+	p.positive("auth.refreshtokenttl", c.Auth.RefreshTokenTTL)
+
+	if c.RateLimit.Enabled {
+		p.oneOf("ratelimit.store", c.RateLimit.Store, limiterStores)
+		p.rule("ratelimit.default", c.RateLimit.Default)
+		for _, name := range sortedKeys(c.RateLimit.Groups) {
+			p.rule("ratelimit.groups."+name, c.RateLimit.Groups[name])
+		}
+	}
+
+	p.positive("idempotency.ttl", c.Idempotency.TTL)
+	p.positive("idempotency.cleanupinterval", c.Idempotency.CleanupInterval)
+
+	p.nonNegative("retention.window", c.Retention.Window)
+	p.positive("retention.purgeinterval", c.Retention.PurgeInterval)
+
+	if c.Metrics.Enabled {
+		p.portString("metrics.port", c.Metrics.Port)
+		if c.Metrics.Port == c.Server.Port {
+			p.addf("metrics.port", "must differ from server.port")
+		}
+	}
+
+	p.oneOf("tracing.exporter", c.Tracing.Exporter, exporters)
+	if c.Tracing.Exporter == "otlpfile" {
+		p.required("tracing.file", c.Tracing.File)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		p.addf("tracing.sampleratio", "%g is not between 0 and 1", c.Tracing.SampleRatio)
+	}
+
+	p.positive("health.timeout", c.Health.Timeout)
+	p.nonNegative("health.draindelay", c.Health.DrainDelay)
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			p.addf("cors.allowedorigins", "%q is not \"*\" or an http(s) origin", origin)
+		}
+	}
+	p.nonNegative("cors.maxage", c.CORS.MaxAge)
+
+	p.required("app.name", c.App.Name)
+	p.required("app.environment", c.App.Environment)
+	p.oneOf("app.loglevel", c.App.LogLevel, logLevels)
+	p.positive("app.starttimeout", c.App.StartTimeout)
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
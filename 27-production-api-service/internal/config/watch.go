@@ -0,0 +1,288 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// live lists the settings subscribers apply while running. Changes to any
+// other setting are logged as pending until the next restart.
+var live = []string{"app.loglevel", "cors", "ratelimit.default", "ratelimit.groups"}
+
+// debounce coalesces the burst of events editors produce when saving
+const debounce = 100 * time.Millisecond
+
+// Change is published after a reload that passed validation and changed
+// at least one setting
+type Change struct {
+	Old, New *Config
+	// Keys lists the changed settings, e.g. "ratelimit.groups.auth.burst"
+	Keys []string
+}
+
+// Changed reports whether the setting key, or any setting below it,
+// changed
+func (c Change) Changed(key string) bool {
+	for _, k := range c.Keys {
+		if under(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func under(key, prefix string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+".")
+}
+
+// isLive reports whether key is applied without a restart
+func isLive(key string) bool {
+	for _, prefix := range live {
+		if under(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff lists the settings that differ between old and new by their
+// config keys
+func Diff(old, new *Config) []string {
+	a, b := map[string]interface{}{}, map[string]interface{}{}
+	flatten("", reflect.ValueOf(*old), a)
+	flatten("", reflect.ValueOf(*new), b)
+
+	var keys []string
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !reflect.DeepEqual(v, w) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flatten records the leaves of v under their viper keys: lower-cased
+// field names joined with dots
+func flatten(prefix string, v reflect.Value, out map[string]interface{}) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name := strings.ToLower(v.Type().Field(i).Name)
+			if prefix == "" && name == "file" {
+				continue
+			}
+			flatten(join(name), v.Field(i), out)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			flatten(join(fmt.Sprint(k.Interface())), v.MapIndex(k), out)
+		}
+	default:
+		out[prefix] = v.Interface()
+	}
+}
+
+// Watcher reloads the configuration when its file changes or the process
+// receives SIGHUP. A reload that fails to read or validate is logged and
+// the running configuration is kept.
+type Watcher struct {
+	path   string
+	logger zerolog.Logger
+
+	mu      sync.Mutex
+	started *Config
+	current *Config
+	subs    []func(Change)
+
+	done chan struct{}
+}
+
+// WatchModule reloads configuration while the server runs
+var WatchModule = fx.Options(
+	fx.Provide(NewWatcher),
+)
+
+// NewWatcher creates a watcher for cfg that runs between the lifecycle's
+// start and stop
+func NewWatcher(lc fx.Lifecycle, cfg *Config, logger zerolog.Logger) *Watcher {
+	w := newWatcher(cfg, logger)
+
+	var fs *fsnotify.Watcher
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if w.path != "" {
+				var err error
+				if fs, err = fsnotify.NewWatcher(); err != nil {
+					return fmt.Errorf("failed to watch config: %w", err)
+				}
+				// Watch the directory: editors and Kubernetes replace the
+				// file rather than writing to it
+				if err := fs.Add(filepath.Dir(w.path)); err != nil {
+					fs.Close()
+					return fmt.Errorf("failed to watch config: %w", err)
+				}
+			}
+
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			go w.run(fs, hup)
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			close(w.done)
+			if fs != nil {
+				return fs.Close()
+			}
+			return nil
+		},
+	})
+
+	return w
+}
+
+func newWatcher(cfg *Config, logger zerolog.Logger) *Watcher {
+	path := cfg.File
+	if path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	return &Watcher{
+		path:    path,
+		logger:  logger.With().Str("component", "config").Logger(),
+		started: cfg,
+		current: cfg,
+		done:    make(chan struct{}),
+	}
+}
+
+// Current returns the configuration as of the last successful reload
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Subscribe registers fn to be called with every change. Subscribers run
+// one at a time in registration order.
+func (w *Watcher) Subscribe(fn func(Change)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs = append(w.subs, fn)
+}
+
+// Reload reads and validates the configuration again and, if anything
+// changed, notifies subscribers. Changed settings that only take effect on
+// restart are logged as pending.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next, err := New(w.path)
+	if err != nil {
+		w.logger.Error().Err(err).Msg("Config reload failed, keeping the running config")
+		return err
+	}
+
+	change := Change{Old: w.current, New: next, Keys: Diff(w.current, next)}
+	if len(change.Keys) == 0 {
+		w.logger.Debug().Msg("Config reloaded without changes")
+		return nil
+	}
+	w.current = next
+
+	for _, fn := range w.subs {
+		fn(change)
+	}
+
+	var applied []string
+	for _, key := range change.Keys {
+		if isLive(key) {
+			applied = append(applied, key)
+		}
+	}
+	if len(applied) > 0 {
+		w.logger.Info().Strs("keys", applied).Msg("Config reloaded")
+	}
+
+	// Compare with the config the process started with, so a pending
+	// change stays reported across reloads until it is reverted
+	var pending []string
+	for _, key := range Diff(w.started, next) {
+		if !isLive(key) {
+			pending = append(pending, key)
+		}
+	}
+	if len(pending) > 0 {
+		w.logger.Warn().Strs("keys", pending).Msg("Config changes pending until restart")
+	}
+	return nil
+}
+
+func (w *Watcher) run(fs *fsnotify.Watcher, hup chan os.Signal) {
+	defer signal.Stop(hup)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if fs != nil {
+		events, errs = fs.Events, fs.Errors
+	}
+	target, _ := filepath.EvalSymlinks(w.path)
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-hup:
+			w.logger.Info().Msg("Reloading config on SIGHUP")
+			w.Reload()
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			// React to the file itself or to a swapped symlink
+			// pointing it elsewhere
+			current, _ := filepath.EvalSymlinks(w.path)
+			if filepath.Clean(ev.Name) == filepath.Clean(w.path) || current != target {
+				target = current
+				timer = time.After(debounce)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			w.logger.Error().Err(err).Msg("Config watch error")
+		case <-timer:
+			timer = nil
+			w.Reload()
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestDiff(t *testing.T) {
+	old := &Config{RateLimit: RateLimitConfig{Groups: map[string]RateLimitRule{
+		"auth":  {Requests: 10, Burst: 5},
+		"posts": {Requests: 100},
+	}}}
+	new := &Config{File: "other.yaml", RateLimit: RateLimitConfig{Groups: map[string]RateLimitRule{
+		"auth":  {Requests: 10, Burst: 2},
+		"users": {Requests: 100},
+	}}}
+	new.Server.Port = "8081"
+
+	want := []string{
+		"ratelimit.groups.auth.burst",
+		"ratelimit.groups.posts.burst", "ratelimit.groups.posts.keyby", "ratelimit.groups.posts.per", "ratelimit.groups.posts.requests",
+		"ratelimit.groups.users.burst", "ratelimit.groups.users.keyby", "ratelimit.groups.users.per", "ratelimit.groups.users.requests",
+		"server.port",
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %q; want %q", got, want)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeFile(t, "api.yaml", "app:\n  loglevel: info\n")
+	cfg, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newWatcher(cfg, zerolog.Nop())
+
+	var changes []Change
+	w.Subscribe(func(c Change) { changes = append(changes, c) })
+
+	if err := w.Reload(); err != nil || len(changes) != 0 {
+		t.Fatalf("unchanged reload: err=%v changes=%d; want no notification", err, len(changes))
+	}
+
+	os.WriteFile(path, []byte("app:\n  loglevel: debug\nserver:\n  port: \"8081\"\n"), 0o600)
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("changes = %d; want 1", len(changes))
+	}
+	c := changes[0]
+	if !c.Changed("app.loglevel") || !c.Changed("server") || c.Changed("cors") {
+		t.Errorf("keys = %q", c.Keys)
+	}
+	if c.Old != cfg || c.New.App.LogLevel != "debug" || w.Current() != c.New {
+		t.Errorf("change = %+v; want old config and new debug level", c)
+	}
+
+	os.WriteFile(path, []byte("app:\n  loglevel: loud\n"), 0o600)
+	if err := w.Reload(); err == nil {
+		t.Error("invalid reload: want error")
+	}
+	if len(changes) != 1 || w.Current().App.LogLevel != "debug" {
+		t.Error("invalid reload replaced the running config")
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := writeFile(t, "api.yaml", "ratelimit:\n  default:\n    burst: 5\n")
+	cfg, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lc := fxtest.NewLifecycle(t)
+	w := NewWatcher(lc, cfg, zerolog.Nop())
+	changed := make(chan Change, 1)
+	w.Subscribe(func(c Change) { changed <- c })
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	// Replace the file the way editors and Kubernetes do
+	tmp := path + ".tmp"
+	os.WriteFile(tmp, []byte("ratelimit:\n  default:\n    burst: 9\n"), 0o600)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case c := <-changed:
+		if c.New.RateLimit.Default.Burst != 9 {
+			t.Errorf("burst = %d; want 9", c.New.RateLimit.Default.Burst)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the file changed")
+	}
+}
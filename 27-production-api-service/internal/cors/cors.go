@@ -0,0 +1,132 @@
+// Package cors lets browsers on configured origins call the API. The
+// policy can be replaced on config reload without rebuilding the router.
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/fx"
+
+	"example.com/production-api/internal/config"
+)
+
+// Module provides the CORS middleware
+var Module = fx.Options(
+	fx.Provide(New),
+)
+
+// policy is a CORSConfig prepared for matching
+type policy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	methods     map[string]bool
+	allowMethod string
+	allowHeader string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+func newPolicy(c config.CORSConfig) *policy {
+	p := &policy{
+		origins:     make(map[string]bool, len(c.AllowedOrigins)),
+		methods:     make(map[string]bool, len(c.AllowedMethods)),
+		allowMethod: strings.Join(c.AllowedMethods, ", "),
+		allowHeader: strings.Join(c.AllowedHeaders, ", "),
+		expose:      strings.Join(c.ExposedHeaders, ", "),
+		credentials: c.AllowCredentials,
+	}
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			p.anyOrigin = true
+		}
+		p.origins[strings.ToLower(o)] = true
+	}
+	for _, m := range c.AllowedMethods {
+		p.methods[strings.ToUpper(m)] = true
+	}
+	if c.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(c.MaxAge.Seconds()))
+	}
+	return p
+}
+
+func (p *policy) allows(origin string) bool {
+	return p.anyOrigin || p.origins[strings.ToLower(origin)]
+}
+
+// CORS answers preflight requests and adds CORS headers to responses for
+// allowed origins
+type CORS struct {
+	policy atomic.Pointer[policy]
+}
+
+// New creates the middleware from the cors configuration
+func New(cfg *config.Config) *CORS {
+	c := &CORS{}
+	c.policy.Store(newPolicy(cfg.CORS))
+	return c
+}
+
+// Apply switches to the policy of a reloaded config
+func (c *CORS) Apply(change config.Change) {
+	if change.Changed("cors") {
+		c.policy.Store(newPolicy(change.New.CORS))
+	}
+}
+
+// Handler is the middleware. It must run before authentication:
+// preflight requests carry no credentials and are answered here.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p := c.policy.Load()
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+			if p.allows(origin) && p.methods[method] {
+				p.allowOrigin(h, origin)
+				h.Set("Access-Control-Allow-Methods", p.allowMethod)
+				if p.allowHeader != "" {
+					h.Set("Access-Control-Allow-Headers", p.allowHeader)
+				}
+				if p.maxAge != "" {
+					h.Set("Access-Control-Max-Age", p.maxAge)
+				}
+			}
+			// Without the allow headers the browser rejects the request
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if p.allows(origin) {
+			p.allowOrigin(h, origin)
+			if p.expose != "" {
+				h.Set("Access-Control-Expose-Headers", p.expose)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowOrigin echoes the origin rather than "*" so credentials work with
+// any allowed origin
+func (p *policy) allowOrigin(h http.Header, origin string) {
+	h.Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
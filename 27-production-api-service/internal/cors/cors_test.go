@@ -0,0 +1,89 @@
+package cors
+
+import (
+	"example.com/production-api/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestCORS(origins ...string) *CORS {
+	return New(&config.Config{CORS: config.CORSConfig{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization"},
+		ExposedHeaders: []string{"ETag"},
+		MaxAge:         time.Minute,
+	}})
+}
+
+func serve(c *CORS, method, origin, requestMethod string) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+	req := httptest.NewRequest(method, "/api/posts", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if requestMethod != "" {
+		req.Header.Set("Access-Control-Request-Method", requestMethod)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w, reached
+}
+
+func TestPreflight(t *testing.T) {
+	c := newTestCORS("https://app.example.com")
+
+	w, reached := serve(c, "OPTIONS", "https://app.example.com", "POST")
+	if reached || w.Code != http.StatusNoContent {
+		t.Fatalf("preflight: code=%d reached=%v; want 204 answered by the middleware", w.Code, reached)
+	}
+	h := w.Header()
+	if h.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		h.Get("Access-Control-Allow-Methods") != "GET, POST" ||
+		h.Get("Access-Control-Allow-Headers") != "Authorization" ||
+		h.Get("Access-Control-Max-Age") != "60" {
+		t.Errorf("preflight headers = %v", h)
+	}
+
+	for _, tc := range []struct{ origin, method string }{
+		{"https://evil.example.com", "POST"},
+		{"https://app.example.com", "DELETE"},
+	} {
+		w, _ := serve(c, "OPTIONS", tc.origin, tc.method)
+		if w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("preflight %s %s allowed", tc.origin, tc.method)
+		}
+	}
+}
+
+func TestActualRequest(t *testing.T) {
+	c := newTestCORS("*")
+
+	w, reached := serve(c, "GET", "https://any.example.com", "")
+	if !reached || w.Header().Get("Access-Control-Allow-Origin") != "https://any.example.com" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "ETag" {
+		t.Errorf("reached=%v headers=%v", reached, w.Header())
+	}
+
+	w, reached = serve(c, "GET", "", "")
+	if !reached || w.Header().Get("Vary") != "" {
+		t.Errorf("same-origin request: reached=%v headers=%v", reached, w.Header())
+	}
+}
+
+func TestApply(t *testing.T) {
+	c := newTestCORS()
+	if w, _ := serve(c, "GET", "https://app.example.com", ""); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("CORS without origins allowed a request")
+	}
+
+	next := &config.Config{CORS: config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}}
+	c.Apply(config.Change{New: next, Keys: []string{"cors.allowedorigins"}})
+
+	if w, _ := serve(c, "GET", "https://app.example.com", ""); w.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Error("reloaded origin not allowed")
+	}
+}
@@ -3,8 +3,12 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"example.com/production-api/internal/config"
-	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/logging"
+	"example.com/production-api/internal/migrate"
+	"example.com/production-api/migrations"
 	"fmt"
 
 	"github.com/rs/zerolog"
@@ -14,50 +18,134 @@ import (
 	gormlogger "gorm.io/gorm/logger"
 )
 
-// Module provides database dependencies
+// Module provides the database connection and migration runner
 var Module = fx.Options(
 	fx.Provide(New),
+	fx.Provide(NewMigrator),
 )
 
-// New creates a database connection with lifecycle management
-func New(lc fx.Lifecycle, cfg *config.Config, logger zerolog.Logger) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
-		cfg.Database.Host,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.DBName,
-		cfg.Database.Port,
-		cfg.Database.SSLMode,
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
-	})
+// RequireSchema refuses to start the application unless every embedded
+// migration has been applied cleanly. With database.automigrate enabled
+// pending migrations are applied first.
+var RequireSchema = fx.Invoke(registerSchemaCheck)
+
+// Pool is one of the connection pools behind the database
+type Pool struct {
+	// Name is "primary" or "replica <host>:<port>"
+	Name string
+	DB   *sql.DB
+}
+
+// Pools lists the primary's pool first, then one per replica
+type Pools []Pool
+
+// New creates a database connection with lifecycle management. With
+// database.replicas configured, reads outside transactions are routed to
+// the replicas.
+//
+// Servers are not contacted until the application starts. Start then
+// waits for the primary and every replica, retrying with backoff until
+// app.starttimeout, so the API can start alongside its database.
+func New(lc fx.Lifecycle, cfg *config.Config, logger zerolog.Logger, logLevel *logging.Level) (*gorm.DB, Pools, error) {
+	gormCfg := &gorm.Config{
+		Logger:               logging.NewGormLogger(logger, logLevel, cfg.Database.SlowQueryThreshold).LogMode(gormlogger.Info),
+		DisableAutomaticPing: true,
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn(cfg.Database, cfg.Database.Host, cfg.Database.Port)), gormCfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	primary, err := db.DB()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, nil, fmt.Errorf("failed to get database instance: %w", err)
+	}
+	configurePool(primary, cfg.Database)
+
+	pools := Pools{{Name: "primary", DB: primary}}
+	closeAll := func() error {
+		var errs []error
+		for _, p := range pools {
+			errs = append(errs, p.DB.Close())
+		}
+		return errors.Join(errs...)
+	}
+
+	var replicas []gorm.ConnPool
+	for _, r := range cfg.Database.Replicas {
+		rdb, err := gorm.Open(postgres.Open(dsn(cfg.Database, r.Host, r.Port)), gormCfg)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("failed to open replica %s: %w", r.Host, err)
+		}
+		pool, err := rdb.DB()
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("failed to get replica instance: %w", err)
+		}
+		configurePool(pool, cfg.Database)
+		pools = append(pools, Pool{Name: fmt.Sprintf("replica %s:%d", r.Host, r.Port), DB: pool})
+		replicas = append(replicas, pool)
+	}
+	if len(replicas) > 0 {
+		if err := routeReads(db, replicas); err != nil {
+			closeAll()
+			return nil, nil, err
+		}
 	}
 
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
-			logger.Info().Msg("Database connected")
-
-			// Auto-migrate models
-			if err := db.AutoMigrate(&models.User{}, &models.Post{}); err != nil {
-				return fmt.Errorf("auto-migration failed: %w", err)
+			for _, p := range pools {
+				if err := connect(ctx, p.DB, p.Name, cfg.Database.ConnectBackoff, cfg.Database.ConnectMaxBackoff, logger); err != nil {
+					return err
+				}
 			}
-
-			logger.Info().Msg("Auto-migration completed")
+			logger.Info().Int("replicas", len(replicas)).Msg("Database connected")
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
 			logger.Info().Msg("Closing database connection")
-			sqlDB, err := db.DB()
-			if err != nil {
-				return fmt.Errorf("failed to get database instance: %w", err)
-			}
-			return sqlDB.Close()
+			return closeAll()
 		},
 	})
 
-	return db, nil
+	return db, pools, nil
+}
+
+// configurePool applies the pool settings with database/sql's meaning of
+// zero: unlimited open connections, no idle ones, no expiry
+func configurePool(db *sql.DB, cfg config.DatabaseConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// NewMigrator creates a migration runner for the embedded migrations
+func NewMigrator(db *gorm.DB, logger zerolog.Logger) (*migrate.Runner, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database instance: %w", err)
+	}
+	return migrate.New(sqlDB, migrations.FS, logger)
+}
+
+func registerSchemaCheck(lc fx.Lifecycle, cfg *config.Config, migrator *migrate.Runner, logger zerolog.Logger) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if cfg.Database.AutoMigrate {
+				if err := migrator.Up(ctx); err != nil {
+					return fmt.Errorf("auto-migration failed: %w", err)
+				}
+			}
+
+			if err := migrator.Verify(ctx); err != nil {
+				return fmt.Errorf("database schema not ready (run `api migrate up`): %w", err)
+			}
+
+			logger.Info().Uint("version", migrator.Latest()).Msg("Database schema verified")
+			return nil
+		},
+	})
 }
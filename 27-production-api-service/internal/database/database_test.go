@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"example.com/production-api/internal/config"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestDSNQuotesValues(t *testing.T) {
+	cfg := config.DatabaseConfig{
+		User:     "api user",
+		Password: `p@ss 'word' \ with=signs`,
+		DBName:   "tutorial",
+		SSLMode:  "verify-full",
+	}
+
+	parsed, err := pgconn.ParseConfig(dsn(cfg, "db.internal", 6432))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if parsed.Host != "db.internal" || parsed.Port != 6432 || parsed.User != cfg.User ||
+		parsed.Password != cfg.Password || parsed.Database != cfg.DBName {
+		t.Errorf("parsed = %s:%d user=%q password=%q db=%q", parsed.Host, parsed.Port, parsed.User, parsed.Password, parsed.Database)
+	}
+	if parsed.TLSConfig == nil {
+		t.Error("sslmode verify-full was not applied")
+	}
+}
+
+// fakePool stands in for a replica; DryRun never executes statements
+type fakePool struct{ gorm.ConnPool }
+
+// fakeTx stands in for the *sql.Tx of a transaction
+type fakeTx struct{ gorm.ConnPool }
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+func TestRouteReads(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1, r2 := &fakePool{}, &fakePool{}
+	if err := routeReads(db, []gorm.ConnPool{r1, r2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the pool each statement ends up on
+	var used []gorm.ConnPool
+	record := func(tx *gorm.DB) { used = append(used, tx.Statement.ConnPool) }
+	db.Callback().Query().After("gorm:query").Register("test:record", record)
+	db.Callback().Create().After("gorm:create").Register("test:record", record)
+	db.Callback().Update().After("gorm:update").Register("test:record", record)
+	db.Callback().Raw().After("gorm:raw").Register("test:record", record)
+
+	primary := db.Config.ConnPool
+	ctx := context.Background()
+	var w widget
+	var ws []widget
+
+	query := db.WithContext(ctx).Model(&widget{}).Where("name = ?", "a")
+	query.Find(&ws)
+	db.First(&w, 1)
+	query.Update("name", "b") // chained off a routed read
+	db.Create(&widget{Name: "c"})
+	db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&w, 1)
+	db.Exec("SELECT 1")
+
+	inTx := db.Session(&gorm.Session{NewDB: true})
+	tx := &fakeTx{}
+	inTx.Statement.ConnPool = tx
+	inTx.Find(&ws)
+
+	want := []gorm.ConnPool{r2, r1, primary, primary, primary, primary, tx}
+	names := map[gorm.ConnPool]string{primary: "primary", r1: "replica 1", r2: "replica 2", tx: "transaction"}
+	if len(used) != len(want) {
+		t.Fatalf("recorded %d statements; want %d", len(used), len(want))
+	}
+	for i := range want {
+		if used[i] != want[i] {
+			t.Errorf("statement %d ran on %s; want %s", i, names[used[i]], names[want[i]])
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"example.com/production-api/internal/config"
+	"strconv"
+	"strings"
+)
+
+// dsn builds a keyword/value connection string for host and port with
+// the credentials of cfg. Every value is quoted, so passwords may contain
+// spaces, quotes and backslashes.
+func dsn(cfg config.DatabaseConfig, host string, port int) string {
+	pairs := []struct{ key, value string }{
+		{"host", host},
+		{"port", strconv.Itoa(port)},
+		{"user", cfg.User},
+		{"password", cfg.Password},
+		{"dbname", cfg.DBName},
+		{"sslmode", cfg.SSLMode},
+	}
+
+	var b strings.Builder
+	for i, p := range pairs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.key)
+		b.WriteByte('=')
+		b.WriteString(quote(p.value))
+	}
+	return b.String()
+}
+
+var quoter = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// quote wraps v in single quotes as libpq expects
+func quote(v string) string {
+	return "'" + quoter.Replace(v) + "'"
+}
@@ -0,0 +1,4 @@
+package database
+
+// RouteReads lets the external tests put replicas in front of a database
+var RouteReads = routeReads
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// primaryKey marks a context whose reads must see the latest writes
+type primaryKey struct{}
+
+// Primary returns a context whose reads run on the primary. Use it for
+// lookups that must see a write made just before, e.g. a token issued a
+// moment ago, which a lagging replica may not have yet.
+func Primary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// replicaRouter sends reads outside transactions to the replicas in turn.
+// Writes, raw SQL, locking reads, reads with a Primary context and
+// everything inside a transaction run on the primary.
+type replicaRouter struct {
+	pools []gorm.ConnPool
+	next  atomic.Uint64
+}
+
+// routeReads installs the callbacks that route statements of db
+func routeReads(db *gorm.DB, pools []gorm.ConnPool) error {
+	r := &replicaRouter{pools: pools}
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("database:replica", r.read); err != nil {
+		return err
+	}
+
+	// A statement chained off a routed read must not write to a replica
+	if err := cb.Create().Before("gorm:begin_transaction").Register("database:primary", r.primary); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:begin_transaction").Register("database:primary", r.primary); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:begin_transaction").Register("database:primary", r.primary); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("database:primary", r.primary); err != nil {
+		return err
+	}
+	return cb.Raw().Before("gorm:raw").Register("database:primary", r.primary)
+}
+
+func (r *replicaRouter) read(tx *gorm.DB) {
+	if tx.Error != nil {
+		return
+	}
+	if _, inTx := tx.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	_, locking := tx.Statement.Clauses["FOR"]
+	if locking || onPrimary(tx.Statement.Context) {
+		tx.Statement.ConnPool = tx.Config.ConnPool
+		return
+	}
+	n := r.next.Add(1)
+	tx.Statement.ConnPool = r.pools[n%uint64(len(r.pools))]
+}
+
+func (r *replicaRouter) primary(tx *gorm.DB) {
+	for _, p := range r.pools {
+		if tx.Statement.ConnPool == p {
+			tx.Statement.ConnPool = tx.Config.ConnPool
+			return
+		}
+	}
+}
+
+func onPrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
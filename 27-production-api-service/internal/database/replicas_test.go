@@ -0,0 +1,176 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"example.com/production-api/internal/database"
+	"example.com/production-api/internal/idempotency"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/repository"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// node is a fake server holding at most one row, which answers every
+// query. A replica without the row is one lagging behind the primary.
+type node struct {
+	row      map[string]driver.Value
+	affected int64
+}
+
+func (n *node) Connect(context.Context) (driver.Conn, error) { return nodeConn{n}, nil }
+func (n *node) Driver() driver.Driver                        { return nil }
+
+type nodeConn struct{ n *node }
+
+func (c nodeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c nodeConn) Close() error                        { return nil }
+func (c nodeConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c nodeConn) Commit() error                       { return nil }
+func (c nodeConn) Rollback() error                     { return nil }
+
+func (c nodeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(c.n.affected), nil
+}
+
+func (c nodeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "count(") {
+		count := int64(0)
+		if c.n.row != nil {
+			count = 1
+		}
+		return &nodeRows{columns: []string{"count"}, rows: [][]driver.Value{{count}}}, nil
+	}
+
+	rows := &nodeRows{}
+	for col := range c.n.row {
+		rows.columns = append(rows.columns, col)
+	}
+	sort.Strings(rows.columns)
+	if c.n.row != nil {
+		row := make([]driver.Value, len(rows.columns))
+		for i, col := range rows.columns {
+			row[i] = c.n.row[col]
+		}
+		rows.rows = append(rows.rows, row)
+	}
+	return rows, nil
+}
+
+type nodeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *nodeRows) Columns() []string { return r.columns }
+func (r *nodeRows) Close() error      { return nil }
+
+func (r *nodeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// laggingDB routes reads to a replica that has none of primary's rows
+func laggingDB(t *testing.T, primary *node) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(primary)}), &gorm.Config{
+		Logger:               gormlogger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RouteReads(db, []gorm.ConnPool{sql.OpenDB(&node{})}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestReadAfterWriteWithLaggingReplica(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		primary *node
+		run     func(db *gorm.DB) error
+	}{
+		{
+			name:    "restored user is read back",
+			primary: &node{row: map[string]driver.Value{"id": int64(1), "name": "Ada"}, affected: 1},
+			run: func(db *gorm.DB) error {
+				user, err := repository.NewUserRepository(db).Restore(ctx, 1)
+				if err == nil && user.Name != "Ada" {
+					err = errors.New("restored user not returned")
+				}
+				return err
+			},
+		},
+		{
+			name:    "stale version is a conflict",
+			primary: &node{row: map[string]driver.Value{"id": int64(1)}},
+			run: func(db *gorm.DB) error {
+				err := repository.NewUserRepository(db).Update(ctx, &models.User{ID: 1, Version: 1})
+				if errors.Is(err, repository.ErrVersionConflict) {
+					return nil
+				}
+				return err
+			},
+		},
+		{
+			name:    "new refresh token is found",
+			primary: &node{row: map[string]driver.Value{"id": int64(1), "token_hash": "h", "expires_at": now}},
+			run: func(db *gorm.DB) error {
+				_, err := repository.NewRefreshTokenRepository(db).GetByHash(ctx, "h")
+				return err
+			},
+		},
+		{
+			name:    "new API key is found",
+			primary: &node{row: map[string]driver.Value{"id": int64(1), "key_hash": "h"}},
+			run: func(db *gorm.DB) error {
+				_, err := repository.NewAPIKeyRepository(db).GetByHash(ctx, "h")
+				return err
+			},
+		},
+		{
+			name: "claimed idempotency key is found",
+			primary: &node{row: map[string]driver.Value{
+				"key": "k", "fingerprint": "f", "status": int64(201), "created_at": now, "expires_at": now.Add(time.Hour),
+			}},
+			run: func(db *gorm.DB) error {
+				existing, err := idempotency.NewPostgresStore(db).Claim(ctx, &idempotency.Record{Key: "k"}, now)
+				if err == nil && (existing == nil || existing.Status != 201) {
+					err = errors.New("existing record not returned")
+				}
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(laggingDB(t, tt.primary)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	// Plain lookups still go to the replica
+	db := laggingDB(t, &node{row: map[string]driver.Value{"id": int64(1)}})
+	if _, err := repository.NewUserRepository(db).Get(ctx, 1, repository.UserQuery{}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Get = %v; want not found on the lagging replica", err)
+	}
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+)
+
+// backoff returns the wait before retrying after failed attempt n,
+// counted from 1: base doubled per attempt and capped at max, with full
+// jitter so replicas starting together do not retry in lockstep
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	d := max
+	if attempt < 32 {
+		if exp := base << (attempt - 1); exp > 0 && exp < max {
+			d = exp
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d))) + 1
+}
+
+// sleep waits for d unless ctx ends first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// connect pings pool until the server answers. Attempts back off
+// exponentially and stop before ctx, the fx start context, ends: fx
+// reports only "context deadline exceeded" once it has, so giving up
+// early lets the last connection error through.
+func connect(ctx context.Context, pool *sql.DB, name string, base, max time.Duration, logger zerolog.Logger) error {
+	var last error
+	for attempt := 1; ; attempt++ {
+		err := pool.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil && last != nil {
+			// The deadline cut this attempt short; the one before tells
+			// why the server is unreachable
+			return fmt.Errorf("failed to connect to %s after %d attempts: %w", name, attempt-1, last)
+		}
+		last = err
+
+		wait := backoff(attempt, base, max)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			return fmt.Errorf("failed to connect to %s after %d attempts: %w", name, attempt, err)
+		}
+		logger.Warn().Err(err).
+			Str("database", name).
+			Int("attempt", attempt).
+			Dur("retry_in", wait).
+			Msg("Database not reachable, retrying")
+		if sleep(ctx, wait) != nil {
+			return fmt.Errorf("failed to connect to %s after %d attempts: %w", name, attempt, err)
+		}
+	}
+}
+
+// retryable reports whether err aborted a transaction that may succeed
+// when run again: a serialization failure or a deadlock
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// txKey carries the transaction of WithTx in a context
+type txKey struct{}
+
+// txAttempts bounds how often WithTx runs a transaction that keeps hitting
+// serialization failures or deadlocks. Retries back off from txBackoff up
+// to txMaxBackoff.
+const (
+	txAttempts   = 5
+	txBackoff    = 20 * time.Millisecond
+	txMaxBackoff = time.Second
+)
+
+// WithTx runs fn in a transaction. The context passed to fn carries the
+// transaction, and Conn picks it up, so repository calls made with that
+// context take part in it. fn's error rolls the transaction back.
+//
+// Called with a context that already carries a transaction, WithTx runs
+// fn in a savepoint of it: an error rolls back only fn's work and is
+// returned to the enclosing fn.
+//
+// The outermost call runs fn again when the transaction fails with a
+// serialization failure (SQLSTATE 40001) or a deadlock (40P01), so fn must
+// be safe to repeat and should return such errors rather than swallow
+// them.
+func WithTx(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) error {
+	run := func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	}
+
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		// GORM runs a transaction on a transaction in a savepoint
+		return tx.WithContext(ctx).Transaction(run)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := db.WithContext(ctx).Transaction(run)
+		if err == nil || !retryable(err) || attempt == txAttempts {
+			return err
+		}
+		if sleep(ctx, backoff(attempt, txBackoff, txMaxBackoff)) != nil {
+			return err
+		}
+	}
+}
+
+// Conn returns the transaction carried by ctx, or db outside a
+// transaction, bound to ctx. Repositories use it instead of
+// db.WithContext so they join transactions started with WithTx.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// recorder is a database/sql connector that records the statements it
+// receives. It refuses the first refuse connections and fails commits
+// with commitErrs, one per commit.
+type recorder struct {
+	mu         sync.Mutex
+	log        []string
+	dials      int
+	refuse     int
+	commitErrs []error
+}
+
+func (r *recorder) Connect(context.Context) (driver.Conn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dials++
+	if r.dials <= r.refuse {
+		return nil, errors.New("connection refused")
+	}
+	return &recConn{r: r}, nil
+}
+
+func (r *recorder) Driver() driver.Driver { return nil }
+
+func (r *recorder) record(stmt string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Savepoint names are generated; keep the statement kind only
+	if strings.Contains(stmt, "SAVEPOINT") {
+		stmt = stmt[:strings.LastIndex(stmt, " ")]
+	}
+	r.log = append(r.log, stmt)
+}
+
+func (r *recorder) statements() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.log...)
+}
+
+type recConn struct{ r *recorder }
+
+func (c *recConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *recConn) Close() error                        { return nil }
+
+func (c *recConn) Begin() (driver.Tx, error) {
+	c.r.record("BEGIN")
+	return c, nil
+}
+
+func (c *recConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.r.record(query)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *recConn) Commit() error {
+	c.r.record("COMMIT")
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	if len(c.r.commitErrs) == 0 {
+		return nil
+	}
+	err := c.r.commitErrs[0]
+	c.r.commitErrs = c.r.commitErrs[1:]
+	return err
+}
+
+func (c *recConn) Rollback() error {
+	c.r.record("ROLLBACK")
+	return nil
+}
+
+func newRecordedDB(t *testing.T, r *recorder) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(r)}), &gorm.Config{
+		Logger:               gormlogger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestWithTxNestsSavepoints(t *testing.T) {
+	r := &recorder{}
+	db := newRecordedDB(t, r)
+	ctx := context.Background()
+
+	err := WithTx(ctx, db, func(ctx context.Context) error {
+		Conn(ctx, db).Exec("INSERT a")
+		failed := WithTx(ctx, db, func(ctx context.Context) error {
+			Conn(ctx, db).Exec("INSERT b")
+			return errors.New("rejected")
+		})
+		if failed == nil {
+			t.Error("nested WithTx swallowed the error")
+		}
+		return WithTx(ctx, db, func(ctx context.Context) error {
+			return Conn(ctx, db).Exec("INSERT c").Error
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Conn(ctx, db).Exec("INSERT d")
+
+	want := []string{
+		"BEGIN", "INSERT a",
+		"SAVEPOINT", "INSERT b", "ROLLBACK TO SAVEPOINT",
+		"SAVEPOINT", "INSERT c",
+		"COMMIT",
+		"INSERT d",
+	}
+	if got := r.statements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("statements = %q; want %q", got, want)
+	}
+}
+
+func TestWithTxRetries(t *testing.T) {
+	serialization := &pgconn.PgError{Code: "40001"}
+	deadlock := &pgconn.PgError{Code: "40P01"}
+	duplicate := &pgconn.PgError{Code: "23505"}
+
+	tests := []struct {
+		name       string
+		commitErrs []error
+		wantRuns   int
+		wantErr    error
+	}{
+		{"serialization failure and deadlock", []error{serialization, deadlock}, 3, nil},
+		{"constraint violation", []error{duplicate}, 1, duplicate},
+		{"attempts exhausted", []error{serialization, serialization, serialization, serialization, serialization, serialization}, txAttempts, serialization},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newRecordedDB(t, &recorder{commitErrs: tt.commitErrs})
+
+			runs := 0
+			err := WithTx(context.Background(), db, func(ctx context.Context) error {
+				runs++
+				return nil
+			})
+			if runs != tt.wantRuns {
+				t.Errorf("fn ran %d times; want %d", runs, tt.wantRuns)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConnectRetries(t *testing.T) {
+	r := &recorder{refuse: 2}
+	err := connect(context.Background(), sql.OpenDB(r), "primary", time.Millisecond, 10*time.Millisecond, zerolog.Nop())
+	if err != nil || r.dials != 3 {
+		t.Errorf("connect: err=%v after %d dials; want success on the third", err, r.dials)
+	}
+
+	// Give up when the start context ends, reporting why
+	r = &recorder{refuse: 1 << 30}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = connect(ctx, sql.OpenDB(r), "primary", time.Millisecond, 10*time.Millisecond, zerolog.Nop())
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("err = %v; want the last connection error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("connect kept retrying for %s after the deadline", elapsed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := 100*time.Millisecond, 2*time.Second
+	for attempt := 1; attempt <= 40; attempt++ {
+		limit := max
+		if attempt <= 5 {
+			limit = base << (attempt - 1)
+		}
+		for i := 0; i < 50; i++ {
+			if d := backoff(attempt, base, max); d <= 0 || d > limit {
+				t.Fatalf("backoff(%d) = %s; want within (0, %s]", attempt, d, limit)
+			}
+		}
+	}
+}
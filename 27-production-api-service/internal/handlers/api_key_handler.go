@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/pagination"
+	"example.com/production-api/internal/services"
+	"net/http"
+	"time"
+)
+
+// defaultRotationOverlap is how long a rotated key keeps working when
+// the request does not say
+const defaultRotationOverlap = 24 * time.Hour
+
+// APIKeyHandler handles the admin API key endpoints
+type APIKeyHandler struct {
+	keys *services.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler with injected dependencies
+func NewAPIKeyHandler(keys *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		keys: keys,
+	}
+}
+
+// issuedAPIKey is returned once when a key is created or rotated
+type issuedAPIKey struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+type rotateRequest struct {
+	// Overlap is a Go duration such as "24h"
+	Overlap string `json:"overlap"`
+}
+
+// apiKeyListSpec defines sorting and filtering accepted by List
+var apiKeyListSpec = pagination.Spec{
+	Sorts: map[string]string{
+		"id":         "id",
+		"name":       "name",
+		"created_at": "created_at",
+	},
+	DefaultSort: "id",
+	Filters: []pagination.Filter{
+		{Param: "user_id", Column: "user_id", Op: pagination.Equal, Kind: pagination.Integer},
+		{Param: "name", Column: "name", Op: pagination.Prefix},
+	},
+}
+
+// List returns a page of API keys
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	req, err := pagination.Parse(r, apiKeyListSpec)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	keys, page, err := h.keys.List(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page.WriteHeaders(w)
+	respondJSON(w, http.StatusOK, keys)
+}
+
+// Get returns a single API key
+func (h *APIKeyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "keyID")
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("invalid API key ID"))
+		return
+	}
+
+	key, err := h.keys.Get(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, key)
+}
+
+// Create issues a new API key. The key is only shown in this response.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var in services.NewAPIKey
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		apierror.Write(w, r, apierror.InvalidJSON(err))
+		return
+	}
+
+	key, plaintext, err := h.keys.Create(r.Context(), in)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusCreated, issuedAPIKey{APIKey: key, Key: plaintext})
+}
+
+// Rotate replaces a key. The old key stays valid for the requested
+// overlap, 24 hours by default.
+func (h *APIKeyHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "keyID")
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("invalid API key ID"))
+		return
+	}
+
+	var req rotateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Write(w, r, apierror.InvalidJSON(err))
+			return
+		}
+	}
+
+	overlap := defaultRotationOverlap
+	if req.Overlap != "" {
+		overlap, err = time.ParseDuration(req.Overlap)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("overlap must be a duration such as 24h"))
+			return
+		}
+	}
+
+	key, plaintext, err := h.keys.Rotate(r.Context(), id, overlap)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusCreated, issuedAPIKey{APIKey: key, Key: plaintext})
+}
+
+// Revoke disables a key immediately
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "keyID")
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("invalid API key ID"))
+		return
+	}
+
+	if err := h.keys.Revoke(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
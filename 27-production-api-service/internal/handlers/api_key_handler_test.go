@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type issuedKey struct {
+	ID         uint    `json:"id"`
+	Key        string  `json:"key"`
+	LastUsedAt *string `json:"last_used_at"`
+}
+
+func createKey(t *testing.T, h http.Handler, body string) issuedKey {
+	t.Helper()
+	w := do(t, h, "POST", "/api/admin/api-keys", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create key: status = %d; want 201: %s", w.Code, w.Body)
+	}
+	var key issuedKey
+	json.NewDecoder(w.Body).Decode(&key)
+	return key
+}
+
+func doWithKey(t *testing.T, h http.Handler, method, path, body, key string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("X-API-Key", key)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAPIKeyActsAsOwnerWithinScopes(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Batch","email":"batch@example.com"}`)
+	key := createKey(t, router, `{"user_id":1,"name":"nightly import","scopes":["posts:write"]}`)
+	if !strings.HasPrefix(key.Key, "pak_") {
+		t.Fatalf("key = %q; want pak_ prefix", key.Key)
+	}
+
+	if w := doWithKey(t, router, "POST", "/api/posts", `{"title":"Imported"}`, key.Key); w.Code != http.StatusCreated {
+		t.Errorf("in-scope write: status = %d; want 201: %s", w.Code, w.Body)
+	}
+	if w := doWithKey(t, router, "PUT", "/api/users/1", `{"name":"Renamed"}`, key.Key); w.Code != http.StatusForbidden {
+		t.Errorf("out-of-scope write: status = %d; want 403", w.Code)
+	}
+	if w := doWithKey(t, router, "GET", "/api/users/1", "", "pak_unknown"); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown key: status = %d; want 401", w.Code)
+	}
+
+	w := do(t, router, "GET", "/api/admin/api-keys/2", "")
+	var stored issuedKey
+	json.NewDecoder(w.Body).Decode(&stored)
+	if stored.LastUsedAt == nil {
+		t.Error("last_used_at not recorded after use")
+	}
+	if strings.Contains(w.Body.String(), key.Key) {
+		t.Error("stored key is returned in plaintext")
+	}
+}
+
+func TestAPIKeyRotationOverlap(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Batch","email":"batch@example.com"}`)
+	old := createKey(t, router, `{"user_id":1,"name":"job","scopes":["posts:write"]}`)
+
+	w := do(t, router, "POST", "/api/admin/api-keys/2/rotate", `{"overlap":"1h"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("rotate: status = %d; want 201: %s", w.Code, w.Body)
+	}
+	var next issuedKey
+	json.NewDecoder(w.Body).Decode(&next)
+
+	for name, key := range map[string]string{"old key within overlap": old.Key, "new key": next.Key} {
+		if w := doWithKey(t, router, "POST", "/api/posts", `{"title":"x"}`, key); w.Code != http.StatusCreated {
+			t.Errorf("%s: status = %d; want 201", name, w.Code)
+		}
+	}
+
+	// Rotating again without overlap retires the current key at once
+	do(t, router, "POST", "/api/admin/api-keys/3/rotate", `{"overlap":"0s"}`)
+	if w := doWithKey(t, router, "POST", "/api/posts", `{"title":"x"}`, next.Key); w.Code != http.StatusUnauthorized {
+		t.Errorf("key rotated without overlap: status = %d; want 401", w.Code)
+	}
+
+	do(t, router, "DELETE", "/api/admin/api-keys/2", "")
+	if w := doWithKey(t, router, "POST", "/api/posts", `{"title":"x"}`, old.Key); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status = %d; want 401", w.Code)
+	}
+}
+
+func TestAPIKeyEndpointsAreAdminOnly(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Admin","email":"admin@example.com","role":"admin"}`)
+	key := createKey(t, router, `{"user_id":1,"name":"limited admin key","scopes":["users:write"]}`)
+
+	if w := doWithToken(t, router, "GET", "/api/admin/api-keys", "", tokenFor(t, 1, "user")); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d; want 403", w.Code)
+	}
+	if w := doWithKey(t, router, "GET", "/api/admin/api-keys", "", key.Key); w.Code != http.StatusForbidden {
+		t.Errorf("admin's key without admin scope: status = %d; want 403", w.Code)
+	}
+	if w := do(t, router, "POST", "/api/admin/api-keys", `{"user_id":1,"name":"x","scopes":["everything"]}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unknown scope: status = %d; want 422", w.Code)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/audit"
+	"example.com/production-api/internal/pagination"
+	"net/http"
+)
+
+// AuditHandler handles the admin audit trail endpoint
+type AuditHandler struct {
+	entries audit.Store
+}
+
+// NewAuditHandler creates a new audit handler with injected dependencies
+func NewAuditHandler(entries audit.Store) *AuditHandler {
+	return &AuditHandler{
+		entries: entries,
+	}
+}
+
+// auditListSpec defines sorting and filtering accepted by List
+var auditListSpec = pagination.Spec{
+	Sorts: map[string]string{
+		"id":         "id",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-id",
+	Filters: []pagination.Filter{
+		{Param: "resource", Column: "resource", Op: pagination.Equal},
+		{Param: "resource_id", Column: "resource_id", Op: pagination.Equal, Kind: pagination.Integer},
+		{Param: "action", Column: "action", Op: pagination.Equal},
+		{Param: "actor_id", Column: "actor_id", Op: pagination.Equal, Kind: pagination.Integer},
+		{Param: "api_key_id", Column: "api_key_id", Op: pagination.Equal, Kind: pagination.Integer},
+		{Param: "request_id", Column: "request_id", Op: pagination.Equal},
+		{Param: "created_after", Column: "created_at", Op: pagination.After},
+		{Param: "created_before", Column: "created_at", Op: pagination.Before},
+	},
+}
+
+// List returns a page of audit entries, newest first
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	req, err := pagination.Parse(r, auditListSpec)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	entries, page, err := h.entries.List(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page.WriteHeaders(w)
+	respondJSON(w, http.StatusOK, entries)
+}
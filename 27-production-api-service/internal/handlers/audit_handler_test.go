@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/audit"
+	"example.com/production-api/internal/models"
+	"net/http"
+	"testing"
+)
+
+func listAudit(t *testing.T, h http.Handler, query string) []audit.Entry {
+	t.Helper()
+	w := do(t, h, "GET", "/api/audit"+query, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/audit%s: status = %d: %s", query, w.Code, w.Body)
+	}
+	var entries []audit.Entry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return entries
+}
+
+func TestAuditRecordsUserChanges(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	doConditional(t, router, "PUT", "/api/users/1", `{"name":"Alice","email":"alice@example.org"}`, "X-Request-Id", "req-42")
+	do(t, router, "DELETE", "/api/users/1", "")
+	do(t, router, "POST", "/api/users/1/restore", "")
+
+	entries := listAudit(t, router, "?resource=users&resource_id=1")
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	want := []string{audit.ActionRestore, audit.ActionDelete, audit.ActionUpdate, audit.ActionCreate}
+	if len(actions) != len(want) {
+		t.Fatalf("actions = %v; want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("actions = %v; want %v", actions, want)
+		}
+	}
+
+	update := entries[2]
+	if update.ActorID != 1 || update.RequestID != "req-42" {
+		t.Errorf("update by actor %d in request %q; want 1 in req-42", update.ActorID, update.RequestID)
+	}
+	email := update.Changes["email"]
+	if email.Before != "alice@example.com" || email.After != "alice@example.org" {
+		t.Errorf("email change = %+v", email)
+	}
+	if _, ok := update.Changes["name"]; ok {
+		t.Error("unchanged name was recorded")
+	}
+	if _, ok := update.Changes["updated_at"]; ok {
+		t.Error("updated_at was recorded")
+	}
+	if created := entries[3].Changes["name"]; created.Before != nil || created.After != "Alice" {
+		t.Errorf("create name change = %+v; want null to Alice", created)
+	}
+}
+
+func TestAuditFilters(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/users", `{"name":"Bob","email":"bob@example.com"}`)
+	alice := tokenFor(t, 1, models.RoleUser)
+	doWithToken(t, router, "POST", "/api/posts", `{"user_id":1,"title":"Hello"}`, alice)
+	doWithToken(t, router, "POST", "/api/posts/3/publish", "", alice)
+
+	if got := listAudit(t, router, "?resource=posts"); len(got) != 2 {
+		t.Errorf("post entries = %d; want 2", len(got))
+	}
+	if got := listAudit(t, router, "?action=create&limit=1"); len(got) != 1 || got[0].ResourceID != 3 {
+		t.Errorf("newest create = %+v; want post 3", got)
+	}
+	got := listAudit(t, router, "?resource=posts&action=update")
+	if len(got) != 1 || got[0].Changes["published"].After != true {
+		t.Errorf("publish entries = %+v; want one setting published", got)
+	}
+
+	for _, query := range []string{"?sort=title", "?actor_id=abc", "?resource_id=1.5"} {
+		if w := do(t, router, "GET", "/api/audit"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d; want 400", query, w.Code)
+		}
+	}
+	if got := listAudit(t, router, "?actor_id=1&resource=posts"); len(got) != 2 {
+		t.Errorf("entries by actor 1 = %d; want 2", len(got))
+	}
+	if w := doWithToken(t, router, "GET", "/api/audit", "", alice); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d; want 403", w.Code)
+	}
+}
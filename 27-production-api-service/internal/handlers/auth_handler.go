@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/services"
+	"net/http"
+)
+
+// AuthHandler handles registration and token requests
+type AuthHandler struct {
+	auth *services.AuthService
+}
+
+// NewAuthHandler creates a new auth handler with injected dependencies
+func NewAuthHandler(auth *services.AuthService) *AuthHandler {
+	return &AuthHandler{
+		auth: auth,
+	}
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Register creates an account that can log in
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var reg services.Registration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		apierror.Write(w, r, apierror.InvalidJSON(err))
+		return
+	}
+
+	user, err := h.auth.Register(r.Context(), reg)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, user)
+}
+
+// Login exchanges credentials for an access and refresh token
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidJSON(err))
+		return
+	}
+
+	tokens, err := h.auth.Login(r.Context(), req.Email, req.Password)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	respondTokens(w, tokens)
+}
+
+// Refresh rotates a refresh token and issues a new access token
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidJSON(err))
+		return
+	}
+
+	tokens, err := h.auth.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	respondTokens(w, tokens)
+}
+
+// Logout revokes the session a refresh token belongs to
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidJSON(err))
+		return
+	}
+
+	if err := h.auth.Logout(r.Context(), req.RefreshToken); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondTokens writes a token response, which must never be cached
+func respondTokens(w http.ResponseWriter, tokens *services.TokenPair) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, tokens)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/services"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func login(t *testing.T, h http.Handler, body string) services.TokenPair {
+	t.Helper()
+	w := doWithToken(t, h, "POST", "/api/auth/login", body, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status = %d; want 200: %s", w.Code, w.Body)
+	}
+	var tokens services.TokenPair
+	json.NewDecoder(w.Body).Decode(&tokens)
+	return tokens
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	router := newTestRouter()
+
+	w := doWithToken(t, router, "POST", "/api/auth/register",
+		`{"name":"Alice","email":"Alice@example.com","password":"correct horse"}`, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: status = %d; want 201: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "password") {
+		t.Errorf("register response leaks the password hash: %s", w.Body)
+	}
+
+	if w := doWithToken(t, router, "POST", "/api/auth/login",
+		`{"email":"alice@example.com","password":"wrong password"}`, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status = %d; want 401", w.Code)
+	}
+
+	tokens := login(t, router, `{"email":"ALICE@example.com","password":"correct horse"}`)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("tokens = %+v; want access and refresh token", tokens)
+	}
+
+	w = doWithToken(t, router, "POST", "/api/posts", `{"user_id":1,"title":"Hello"}`, tokens.AccessToken)
+	if w.Code != http.StatusCreated {
+		t.Errorf("create post with token: status = %d; want 201: %s", w.Code, w.Body)
+	}
+}
+
+func TestMutationsRequireToken(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	tests := []struct {
+		name          string
+		method, path  string
+		body, token   string
+		wantStatus    int
+		wantChallenge bool
+	}{
+		{"anonymous read", "GET", "/api/posts", "", "", http.StatusOK, false},
+		{"anonymous user read", "GET", "/api/users/1", "", "", http.StatusUnauthorized, true},
+		{"anonymous create", "POST", "/api/users", `{"name":"Bob","email":"bob@example.com"}`, "", http.StatusUnauthorized, true},
+		{"anonymous post update", "PUT", "/api/posts/1", `{"title":"x"}`, "", http.StatusUnauthorized, true},
+		{"garbage token", "GET", "/api/users/1", "", "not-a-jwt", http.StatusUnauthorized, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doWithToken(t, router, tt.method, tt.path, tt.body, tt.token)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := w.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate set = %v; want %v", got, tt.wantChallenge)
+			}
+			if tt.wantStatus == http.StatusUnauthorized {
+				var p apierror.Problem
+				json.NewDecoder(w.Body).Decode(&p)
+				if p.Code != apierror.CodeUnauthorized {
+					t.Errorf("code = %q; want %q", p.Code, apierror.CodeUnauthorized)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	router := newTestRouter()
+	doWithToken(t, router, "POST", "/api/auth/register",
+		`{"name":"Alice","email":"alice@example.com","password":"correct horse"}`, "")
+	first := login(t, router, `{"email":"alice@example.com","password":"correct horse"}`)
+
+	refresh := func(token string) (*services.TokenPair, int) {
+		w := doWithToken(t, router, "POST", "/api/auth/refresh", `{"refresh_token":"`+token+`"}`, "")
+		var tokens services.TokenPair
+		json.NewDecoder(w.Body).Decode(&tokens)
+		return &tokens, w.Code
+	}
+
+	second, status := refresh(first.RefreshToken)
+	if status != http.StatusOK || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh: status = %d, rotated = %v; want 200 and a new token", status, second.RefreshToken != first.RefreshToken)
+	}
+
+	// Replaying the rotated token revokes the whole family, including
+	// the token it was exchanged for
+	if _, status := refresh(first.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("reused token: status = %d; want 401", status)
+	}
+	if _, status := refresh(second.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("token from revoked family: status = %d; want 401", status)
+	}
+}
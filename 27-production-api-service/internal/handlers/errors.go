@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/patch"
+	"example.com/production-api/internal/services"
+	"net/http"
+)
+
+// writeError translates domain errors from the service layer into API
+// problems. Anything unrecognised is left for apierror to map.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		err = apierror.NotFound("user not found")
+	case errors.Is(err, services.ErrPostNotFound):
+		err = apierror.NotFound("post not found")
+	case errors.Is(err, services.ErrEmailTaken):
+		err = apierror.New(http.StatusConflict, apierror.CodeDuplicate, "email is already in use")
+	case errors.Is(err, services.ErrUserHasPosts):
+		err = apierror.Conflict("user still has posts")
+	case errors.Is(err, services.ErrPreconditionFailed):
+		err = apierror.PreconditionFailed("resource was modified since it was read")
+	case errors.Is(err, services.ErrEditConflict):
+		err = apierror.Conflict("resource was modified concurrently, retry the request")
+	case errors.Is(err, patch.ErrInvalid):
+		err = apierror.New(http.StatusBadRequest, apierror.CodeInvalidPatch, err.Error())
+	case errors.Is(err, patch.ErrUnprocessable):
+		err = apierror.New(http.StatusUnprocessableEntity, apierror.CodeInvalidPatch, err.Error())
+	case errors.Is(err, patch.ErrTestFailed):
+		err = apierror.Conflict(err.Error())
+	case errors.Is(err, services.ErrInvalidCredentials):
+		err = apierror.Unauthorized("invalid email or password")
+	case errors.Is(err, services.ErrInvalidToken):
+		err = apierror.Unauthorized("refresh token is invalid or expired")
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		err = apierror.NotFound("API key not found")
+	case errors.Is(err, services.ErrAPIKeyInactive):
+		err = apierror.Conflict("API key is revoked or expired")
+	case errors.Is(err, services.ErrExpiryInPast), errors.Is(err, services.ErrNegativeOverlap):
+		err = apierror.BadRequest(err.Error())
+	}
+	apierror.Write(w, r, err)
+}
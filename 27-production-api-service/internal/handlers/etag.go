@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Entity tags are strong validators built from row versions, e.g. "3".
+// A post with its author embedded also carries the author's version,
+// e.g. "3-7", so either changing produces a new tag.
+
+func userETag(u *models.User) string {
+	return `"` + strconv.FormatUint(uint64(u.Version), 10) + `"`
+}
+
+func postETag(p *models.Post) string {
+	tag := strconv.FormatUint(uint64(p.Version), 10)
+	if p.User != nil {
+		tag += "-" + strconv.FormatUint(uint64(p.User.Version), 10)
+	}
+	return `"` + tag + `"`
+}
+
+// notModified answers 304 when If-None-Match lists the current tag.
+// Otherwise it sets the ETag header for the response that follows.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		// If-None-Match uses weak comparison
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// precondition reads If-Match into the versions a write may apply to.
+// Without the header, or with "*", any version is accepted. Weak and
+// foreign tags never match, as If-Match requires strong comparison.
+func precondition(r *http.Request) services.Precondition {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return services.Precondition{}
+	}
+
+	versions := []uint{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		// Only the resource's own version matters for a write
+		version, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+		if v, err := strconv.ParseUint(version, 10, 64); err == nil {
+			versions = append(versions, uint(v))
+		}
+	}
+	return services.Precondition{Versions: versions}
+}
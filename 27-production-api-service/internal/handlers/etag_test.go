@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// doConditional sends a request as an admin with one extra header
+func doConditional(t *testing.T, h http.Handler, method, path, body, header, value string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+tokenFor(t, 1, models.RoleAdmin))
+	r.Header.Set(header, value)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestGetUserETag(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	w := do(t, router, "GET", "/api/users/1", "")
+	etag := w.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("ETag = %q; want \"1\"", etag)
+	}
+
+	w = doConditional(t, router, "GET", "/api/users/1", "", "If-None-Match", etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("unchanged: status = %d, body %q; want empty 304", w.Code, w.Body)
+	}
+
+	do(t, router, "PUT", "/api/users/1", `{"name":"Alicia","email":"alice@example.com"}`)
+	w = doConditional(t, router, "GET", "/api/users/1", "", "If-None-Match", etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("changed: status = %d, ETag %q; want 200 \"2\"", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestUpdateUserIfMatch(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	w := doConditional(t, router, "PUT", "/api/users/1", `{"name":"Alicia","email":"alice@example.com"}`, "If-Match", `"1"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("matching update: status = %d, ETag %q; want 200 \"2\": %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+
+	// A second client still holding version 1 loses
+	w = doConditional(t, router, "PUT", "/api/users/1", `{"name":"Ali","email":"alice@example.com"}`, "If-Match", `"1"`)
+	var p apierror.Problem
+	json.NewDecoder(w.Body).Decode(&p)
+	if w.Code != http.StatusPreconditionFailed || p.Code != apierror.CodePreconditionFailed {
+		t.Errorf("stale update: got %d %s; want 412 precondition_failed", w.Code, p.Code)
+	}
+
+	w = do(t, router, "GET", "/api/users/1", "")
+	var user models.User
+	json.NewDecoder(w.Body).Decode(&user)
+	if user.Name != "Alicia" || user.Version != 2 {
+		t.Errorf("user = %s v%d; want Alicia v2", user.Name, user.Version)
+	}
+
+	for _, tag := range []string{`W/"2"`, `"bogus"`} {
+		if w := doConditional(t, router, "DELETE", "/api/users/1", "", "If-Match", tag); w.Code != http.StatusPreconditionFailed {
+			t.Errorf("delete with If-Match %s: status = %d; want 412", tag, w.Code)
+		}
+	}
+	if w := doConditional(t, router, "DELETE", "/api/users/1", "", "If-Match", `"1", "2"`); w.Code != http.StatusNoContent {
+		t.Errorf("delete with matching tag: status = %d; want 204", w.Code)
+	}
+}
+
+func TestPostETagIncludesAuthor(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/users/1/posts", `{"title":"Hello"}`)
+
+	if got := do(t, router, "GET", "/api/posts/2?include=user", "").Header().Get("ETag"); got != `"1-1"` {
+		t.Errorf("ETag = %s; want \"1-1\"", got)
+	}
+
+	do(t, router, "PUT", "/api/users/1", `{"name":"Alicia","email":"alice@example.com"}`)
+	w := doConditional(t, router, "GET", "/api/posts/2?include=user", "", "If-None-Match", `"1-1"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"1-2"` {
+		t.Errorf("after author change: status = %d, ETag %s; want 200 \"1-2\"", w.Code, w.Header().Get("ETag"))
+	}
+
+	// Writes compare the post's own version only
+	w = doConditional(t, router, "POST", "/api/posts/2/publish", "", "If-Match", `"1-2"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("publish: status = %d, ETag %s; want 200 \"2\"", w.Code, w.Header().Get("ETag"))
+	}
+	if w := doConditional(t, router, "PUT", "/api/posts/2", `{"title":"Hi"}`, "If-Match", `"1"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale post update: status = %d; want 412", w.Code)
+	}
+}
@@ -8,4 +8,8 @@ import (
 // Module provides handler dependencies
 var Module = fx.Options(
 	fx.Provide(NewUserHandler),
+	fx.Provide(NewPostHandler),
+	fx.Provide(NewAuthHandler),
+	fx.Provide(NewAPIKeyHandler),
+	fx.Provide(NewAuditHandler),
 )
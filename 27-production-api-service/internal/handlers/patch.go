@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/patch"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// decodeFields decodes a resource's writable fields. Any other member,
+// such as id or created_at, is rejected rather than silently dropped.
+func decodeFields(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if name, uerr := strconv.Unquote(field); uerr == nil {
+			field = name
+		}
+		return fieldProblem(apierror.FieldError{Field: field, Rule: "readonly", Message: "cannot be set"})
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fieldProblem(apierror.FieldError{Field: typeErr.Field, Rule: "type", Message: "must be a " + typeErr.Type.String()})
+	}
+	return apierror.InvalidJSON(err)
+}
+
+func fieldProblem(fe apierror.FieldError) *apierror.Problem {
+	p := apierror.New(http.StatusUnprocessableEntity, apierror.CodeValidation, "request validation failed")
+	p.Errors = []apierror.FieldError{fe}
+	return p
+}
+
+// readPatch reads a PATCH body and its patch format. Other media types
+// are answered with 415 and the supported formats in Accept-Patch.
+func readPatch(w http.ResponseWriter, r *http.Request) (string, []byte, bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != patch.MergePatch && mediaType != patch.JSONPatch {
+		w.Header().Set("Accept-Patch", patch.Accept)
+		apierror.Write(w, r, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType,
+			"PATCH requires "+patch.MergePatch+" or "+patch.JSONPatch))
+		return "", nil, false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierror.Write(w, r, apierror.UnreadableBody(err))
+		return "", nil, false
+	}
+	return mediaType, body, true
+}
+
+// applyPatch patches the JSON form of current and decodes the result
+// into next, which must be a pointer to a zero value of the same type
+func applyPatch(mediaType string, body []byte, current, next interface{}) error {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	patched, err := patch.Apply(mediaType, doc, body)
+	if err != nil {
+		return err
+	}
+	return decodeFields(bytes.NewReader(patched), next)
+}
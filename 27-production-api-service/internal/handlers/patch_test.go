@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/patch"
+	"net/http"
+	"testing"
+)
+
+func TestPatchUser(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	tests := []struct {
+		name, mediaType, body string
+		wantStatus            int
+		wantCode              apierror.Code
+		wantName              string
+	}{
+		{"merge patch", patch.MergePatch, `{"name":"Alicia"}`, http.StatusOK, "", "Alicia"},
+		{"json patch", patch.JSONPatch, `[{"op":"test","path":"/name","value":"Alicia"},{"op":"replace","path":"/name","value":"Ally"}]`, http.StatusOK, "", "Ally"},
+		{"merged result is validated", patch.MergePatch, `{"email":null}`, http.StatusUnprocessableEntity, apierror.CodeValidation, "Ally"},
+		{"id cannot be smuggled", patch.MergePatch, `{"id":7}`, http.StatusUnprocessableEntity, apierror.CodeValidation, "Ally"},
+		{"created_at cannot be added", patch.JSONPatch, `[{"op":"add","path":"/created_at","value":"2000-01-01T00:00:00Z"}]`, http.StatusUnprocessableEntity, apierror.CodeValidation, "Ally"},
+		{"wrong type", patch.MergePatch, `{"name":5}`, http.StatusUnprocessableEntity, apierror.CodeValidation, "Ally"},
+		{"failed test", patch.JSONPatch, `[{"op":"test","path":"/name","value":"Alice"}]`, http.StatusConflict, apierror.CodeConflict, "Ally"},
+		{"missing path", patch.JSONPatch, `[{"op":"remove","path":"/nickname"}]`, http.StatusUnprocessableEntity, apierror.CodeInvalidPatch, "Ally"},
+		{"malformed patch", patch.JSONPatch, `{"op":"remove"}`, http.StatusBadRequest, apierror.CodeInvalidPatch, "Ally"},
+		{"plain JSON", "application/json", `{"name":"Al"}`, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType, "Ally"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doConditional(t, router, "PATCH", "/api/users/1", tt.body, "Content-Type", tt.mediaType)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantCode != "" {
+				var p apierror.Problem
+				json.NewDecoder(w.Body).Decode(&p)
+				if p.Code != tt.wantCode {
+					t.Errorf("code = %q; want %q", p.Code, tt.wantCode)
+				}
+			}
+
+			var user models.User
+			json.NewDecoder(do(t, router, "GET", "/api/users/1", "").Body).Decode(&user)
+			if user.Name != tt.wantName || user.ID != 1 {
+				t.Errorf("user = %d %q; want 1 %q", user.ID, user.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestUnsupportedPatchAdvertisesFormats(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	w := do(t, router, "PATCH", "/api/users/1", `{"name":"Al"}`)
+	if got := w.Header().Get("Accept-Patch"); got != patch.Accept {
+		t.Errorf("Accept-Patch = %q; want %q", got, patch.Accept)
+	}
+}
+
+func TestPatchPostSetsZeroValues(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/posts", `{"user_id":1,"title":"Hello","content":"Body"}`)
+	do(t, router, "POST", "/api/posts/2/publish", "")
+
+	w := doConditional(t, router, "PATCH", "/api/posts/2", `{"published":false,"content":""}`, "Content-Type", patch.MergePatch)
+	var post models.Post
+	json.NewDecoder(w.Body).Decode(&post)
+	if w.Code != http.StatusOK || post.Published || post.Content != "" || post.Title != "Hello" {
+		t.Errorf("got %d %+v; want draft titled Hello with empty content", w.Code, post)
+	}
+}
+
+func TestPutReplacesPost(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/posts", `{"user_id":1,"title":"Hello","content":"Body"}`)
+
+	w := do(t, router, "PUT", "/api/posts/2", `{"title":"Hi"}`)
+	var post models.Post
+	json.NewDecoder(w.Body).Decode(&post)
+	if w.Code != http.StatusOK || post.Content != "" || post.UserID != 1 {
+		t.Errorf("got %d %+v; want content cleared and author kept", w.Code, post)
+	}
+
+	w = do(t, router, "PUT", "/api/posts/2", `{"title":"Hi","user_id":2}`)
+	var p apierror.Problem
+	json.NewDecoder(w.Body).Decode(&p)
+	if w.Code != http.StatusUnprocessableEntity || len(p.Errors) != 1 || p.Errors[0].Field != "user_id" || p.Errors[0].Rule != "readonly" {
+		t.Errorf("moving post: got %d %+v; want 422 with user_id readonly", w.Code, p.Errors)
+	}
+}
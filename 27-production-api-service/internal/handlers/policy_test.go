@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestOwnershipPolicies(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/users", `{"name":"Bob","email":"bob@example.com"}`)
+	do(t, router, "POST", "/api/users/1/posts", `{"title":"Alice's post"}`)
+
+	alice := tokenFor(t, 1, models.RoleUser)
+	bob := tokenFor(t, 2, models.RoleUser)
+
+	tests := []struct {
+		name         string
+		method, path string
+		body, token  string
+		wantStatus   int
+	}{
+		{"user creates user", "POST", "/api/users", `{"name":"Eve","email":"eve@example.com"}`, bob, http.StatusForbidden},
+		{"user updates someone else", "PUT", "/api/users/1", `{"name":"Mallory"}`, bob, http.StatusForbidden},
+		{"user updates self", "PUT", "/api/users/2", `{"name":"Robert","email":"bob@example.com"}`, bob, http.StatusOK},
+		{"user edits someone else's post", "PUT", "/api/posts/3", `{"title":"Mine now"}`, bob, http.StatusForbidden},
+		{"user publishes someone else's post", "POST", "/api/users/1/posts/3/publish", "", bob, http.StatusForbidden},
+		{"user posts as someone else", "POST", "/api/posts", `{"user_id":1,"title":"Spoof"}`, bob, http.StatusForbidden},
+		{"user posts on someone else's profile", "POST", "/api/users/1/posts", `{"title":"Spoof"}`, bob, http.StatusForbidden},
+		{"author edits own post", "PUT", "/api/posts/3", `{"title":"Edited"}`, alice, http.StatusOK},
+		{"missing post still 404s", "DELETE", "/api/posts/99", "", bob, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doWithToken(t, router, tt.method, tt.path, tt.body, tt.token)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusForbidden {
+				var p apierror.Problem
+				json.NewDecoder(w.Body).Decode(&p)
+				if p.Code != apierror.CodeForbidden {
+					t.Errorf("code = %q; want %q", p.Code, apierror.CodeForbidden)
+				}
+			}
+		})
+	}
+}
+
+func TestUserReadsRequireAuthentication(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/users", `{"name":"Bob","email":"bob@example.com"}`)
+
+	for _, path := range []string{"/api/users", "/api/users/1", "/api/users?email=alice@example.com"} {
+		w := doWithToken(t, router, "GET", path, "", "")
+		var p apierror.Problem
+		json.NewDecoder(w.Body).Decode(&p)
+		if w.Code != http.StatusUnauthorized || p.Code != apierror.CodeUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("anonymous GET %s: %d %s; want 401 with WWW-Authenticate", path, w.Code, p.Code)
+		}
+	}
+
+	if w := doWithToken(t, router, "GET", "/api/users/1", "", tokenFor(t, 2, models.RoleUser)); w.Code != http.StatusOK {
+		t.Errorf("authenticated GET: status = %d; want 200", w.Code)
+	}
+}
+
+func TestPostCreateDefaultsToCaller(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	w := doWithToken(t, router, "POST", "/api/posts", `{"title":"Hello"}`, tokenFor(t, 1, models.RoleUser))
+	var post models.Post
+	json.NewDecoder(w.Body).Decode(&post)
+	if w.Code != http.StatusCreated || post.UserID != 1 {
+		t.Errorf("status = %d, user_id = %d; want 201 and the caller's ID", w.Code, post.UserID)
+	}
+}
+
+func TestDraftsAreHiddenFromOtherCallers(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/users", `{"name":"Bob","email":"bob@example.com"}`)
+	do(t, router, "POST", "/api/users/1/posts", `{"title":"Draft"}`)
+	do(t, router, "POST", "/api/users/1/posts", `{"title":"Published"}`)
+	do(t, router, "POST", "/api/posts/4/publish", "")
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"anonymous", "", 1},
+		{"other user", tokenFor(t, 2, models.RoleUser), 1},
+		{"author", tokenFor(t, 1, models.RoleUser), 2},
+		{"admin", tokenFor(t, 2, models.RoleAdmin), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doWithToken(t, router, "GET", "/api/posts", "", tt.token)
+			var posts []models.Post
+			json.NewDecoder(w.Body).Decode(&posts)
+			if len(posts) != tt.want {
+				t.Errorf("saw %d posts; want %d", len(posts), tt.want)
+			}
+		})
+	}
+
+	if w := doWithToken(t, router, "GET", "/api/posts/3", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("anonymous read of draft: status = %d; want 404", w.Code)
+	}
+}
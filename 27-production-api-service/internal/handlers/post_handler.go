@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/pagination"
+	"example.com/production-api/internal/policy"
+	"example.com/production-api/internal/repository"
+	"example.com/production-api/internal/services"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PostHandler handles post-related HTTP requests.
+// Every action works both on /api/posts and nested under
+// /api/users/{id}/posts, where the user ID scopes the query.
+type PostHandler struct {
+	posts *services.PostService
+}
+
+// NewPostHandler creates a new post handler with injected dependencies
+func NewPostHandler(posts *services.PostService) *PostHandler {
+	return &PostHandler{
+		posts: posts,
+	}
+}
+
+// postListSpec defines sorting and filtering accepted by List
+var postListSpec = pagination.Spec{
+	Sorts: map[string]string{
+		"id":         "id",
+		"title":      "title",
+		"created_at": "created_at",
+	},
+	DefaultSort: "id",
+	Filters: []pagination.Filter{
+		{Param: "user_id", Column: "user_id", Op: pagination.Equal, Kind: pagination.Integer},
+		{Param: "published", Column: "published", Op: pagination.Equal, Kind: pagination.Boolean},
+		{Param: "title", Column: "title", Op: pagination.Prefix},
+		{Param: "created_after", Column: "created_at", Op: pagination.After},
+		{Param: "created_before", Column: "created_at", Op: pagination.Before},
+	},
+}
+
+// List returns a page of posts, optionally scoped to a user.
+// Use ?include=user to preload each post's author; admins can add
+// ?include_deleted=true.
+func (h *PostHandler) List(w http.ResponseWriter, r *http.Request) {
+	req, err := pagination.Parse(r, postListSpec)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	q, ok := postQuery(w, r)
+	if !ok {
+		return
+	}
+	if q.IncludeDeleted, ok = includeDeleted(w, r); !ok {
+		return
+	}
+
+	posts, page, err := h.posts.List(r.Context(), q, req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page.WriteHeaders(w)
+	respondJSON(w, http.StatusOK, posts)
+}
+
+// Get returns a single post with its ETag
+func (h *PostHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, q, ok := postTarget(w, r)
+	if !ok {
+		return
+	}
+	if q.IncludeDeleted, ok = includeDeleted(w, r); !ok {
+		return
+	}
+
+	post, err := h.posts.Get(r.Context(), id, q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if notModified(w, r, postETag(post)) {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, post)
+}
+
+// newPostRequest holds the fields a client may set when creating a post
+type newPostRequest struct {
+	services.PostInput
+	UserID uint `json:"user_id"`
+}
+
+// Create creates a new post. On the nested route the author is taken
+// from the URL; otherwise it is user_id from the body, defaulting to the
+// caller. Only admins may post for someone else.
+func (h *PostHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var in newPostRequest
+	if err := decodeFields(r.Body, &in); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	post := models.Post{UserID: in.UserID, Title: in.Title, Content: in.Content, Published: in.Published}
+
+	q, ok := postQuery(w, r)
+	if !ok {
+		return
+	}
+	if q.UserID != 0 {
+		post.UserID = q.UserID
+	}
+
+	caller := auth.PrincipalFrom(r.Context())
+	if post.UserID == 0 && caller != nil {
+		post.UserID = caller.UserID
+	}
+	if !policy.Owns(caller, post.UserID) {
+		policy.Deny(w, r, "admin|self")
+		return
+	}
+
+	if err := h.posts.Create(r.Context(), &post); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", postETag(&post))
+	respondJSON(w, http.StatusCreated, post)
+}
+
+// Replace replaces every writable field of a post, if it still matches
+// If-Match. Fields left out are cleared.
+func (h *PostHandler) Replace(w http.ResponseWriter, r *http.Request) {
+	id, q, ok := postTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var in services.PostInput
+	if err := decodeFields(r.Body, &in); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	post, err := h.posts.Replace(r.Context(), id, q, in, precondition(r))
+	respondPost(w, r, post, err)
+}
+
+// Patch applies a JSON Merge Patch or JSON Patch to a post's writable
+// fields, if it still matches If-Match
+func (h *PostHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, q, ok := postTarget(w, r)
+	if !ok {
+		return
+	}
+
+	mediaType, body, ok := readPatch(w, r)
+	if !ok {
+		return
+	}
+
+	post, err := h.posts.Patch(r.Context(), id, q, func(current services.PostInput) (services.PostInput, error) {
+		var next services.PostInput
+		return next, applyPatch(mediaType, body, current, &next)
+	}, precondition(r))
+	respondPost(w, r, post, err)
+}
+
+// Delete soft-deletes a post, if it still matches If-Match
+func (h *PostHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, q, ok := postTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.posts.Delete(r.Context(), id, q, precondition(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Publish marks a post as published
+func (h *PostHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	h.setPublished(w, r, true)
+}
+
+// Unpublish marks a post as a draft
+func (h *PostHandler) Unpublish(w http.ResponseWriter, r *http.Request) {
+	h.setPublished(w, r, false)
+}
+
+func (h *PostHandler) setPublished(w http.ResponseWriter, r *http.Request, published bool) {
+	id, q, ok := postTarget(w, r)
+	if !ok {
+		return
+	}
+
+	post, err := h.posts.SetPublished(r.Context(), id, q, published, precondition(r))
+	respondPost(w, r, post, err)
+}
+
+// respondPost writes the result of a write to an existing post
+func respondPost(w http.ResponseWriter, r *http.Request, post *models.Post, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", postETag(post))
+	respondJSON(w, http.StatusOK, post)
+}
+
+// postQuery reads the author scope from nested routes and ?include=user
+// and hides drafts the caller may not see
+func postQuery(w http.ResponseWriter, r *http.Request) (repository.PostQuery, bool) {
+	q := policy.PostVisibility(auth.PrincipalFrom(r.Context()), repository.PostQuery{
+		IncludeUser: r.URL.Query().Get("include") == "user",
+	})
+
+	if chi.URLParam(r, "id") != "" {
+		userID, err := parseID(r, "id")
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("invalid user ID"))
+			return q, false
+		}
+		q.UserID = userID
+	}
+
+	return q, true
+}
+
+// postTarget reads {postID} and the request scope
+func postTarget(w http.ResponseWriter, r *http.Request) (uint, repository.PostQuery, bool) {
+	id, err := parseID(r, "postID")
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("invalid post ID"))
+		return 0, repository.PostQuery{}, false
+	}
+
+	q, ok := postQuery(w, r)
+	return id, q, ok
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/policy"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// parseID reads a numeric URL parameter such as {id} or {postID}
+func parseID(r *http.Request, param string) (uint, error) {
+	id, err := strconv.ParseUint(chi.URLParam(r, param), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+// includeDeleted reads ?include_deleted=true, which only admins may use
+// to see soft-deleted records
+func includeDeleted(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	value := r.URL.Query().Get("include_deleted")
+	if value == "" {
+		return false, true
+	}
+
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("include_deleted must be true or false"))
+		return false, false
+	}
+	if include && !auth.PrincipalFrom(r.Context()).IsAdmin() {
+		policy.Deny(w, r, "admin")
+		return false, false
+	}
+	return include, true
+}
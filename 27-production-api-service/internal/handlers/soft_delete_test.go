@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/models"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDeletedUserCanBeRestored(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Admin","email":"admin@example.com"}`)
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	if w := do(t, router, "DELETE", "/api/users/2", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status = %d; want 204", w.Code)
+	}
+	if w := do(t, router, "GET", "/api/users/2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get deleted: status = %d; want 404", w.Code)
+	}
+
+	w := do(t, router, "GET", "/api/users/2?include_deleted=true", "")
+	var user models.User
+	json.NewDecoder(w.Body).Decode(&user)
+	if w.Code != http.StatusOK || !user.DeletedAt.Valid {
+		t.Errorf("admin include_deleted: got %d, deleted_at %v; want 200 with deleted_at", w.Code, user.DeletedAt)
+	}
+
+	alice := tokenFor(t, 2, models.RoleUser)
+	if w := doWithToken(t, router, "GET", "/api/users?include_deleted=true", "", alice); w.Code != http.StatusForbidden {
+		t.Errorf("user include_deleted: status = %d; want 403", w.Code)
+	}
+	if w := doWithToken(t, router, "POST", "/api/users/2/restore", "", alice); w.Code != http.StatusForbidden {
+		t.Errorf("user restore: status = %d; want 403", w.Code)
+	}
+
+	w = do(t, router, "POST", "/api/users/2/restore", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore: status = %d; want 200: %s", w.Code, w.Body)
+	}
+	w = do(t, router, "GET", "/api/users/2", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"deleted_at":null`) {
+		t.Errorf("get restored: got %d %s; want 200 with null deleted_at", w.Code, w.Body)
+	}
+	if w := do(t, router, "POST", "/api/users/2/restore", ""); w.Code != http.StatusNotFound {
+		t.Errorf("restore live user: status = %d; want 404", w.Code)
+	}
+}
+
+func TestDeletedUserReleasesEmail(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "DELETE", "/api/users/1", "")
+
+	if w := do(t, router, "POST", "/api/users", `{"name":"Alice Two","email":"alice@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("reuse email: status = %d; want 201: %s", w.Code, w.Body)
+	}
+
+	w := do(t, router, "POST", "/api/users/1/restore", "")
+	var p apierror.Problem
+	json.NewDecoder(w.Body).Decode(&p)
+	if w.Code != http.StatusConflict || p.Code != apierror.CodeDuplicate {
+		t.Errorf("restore with taken email: got %d %s; want 409 duplicate_resource", w.Code, p.Code)
+	}
+
+	w = do(t, router, "GET", "/api/users?include_deleted=true", "")
+	var users []models.User
+	json.NewDecoder(w.Body).Decode(&users)
+	if len(users) != 2 {
+		t.Errorf("include_deleted listed %d users; want 2", len(users))
+	}
+}
+
+func TestDeletedPostsAreHidden(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/users/1/posts", `{"title":"Hello"}`)
+
+	if w := do(t, router, "DELETE", "/api/users/1", ""); w.Code != http.StatusConflict {
+		t.Errorf("delete author of live post: status = %d; want 409", w.Code)
+	}
+
+	do(t, router, "DELETE", "/api/posts/2", "")
+	var posts []models.Post
+	json.NewDecoder(do(t, router, "GET", "/api/posts", "").Body).Decode(&posts)
+	if len(posts) != 0 {
+		t.Errorf("listed %d posts; want deleted post hidden", len(posts))
+	}
+	if w := do(t, router, "GET", "/api/posts/2?include_deleted=true", ""); w.Code != http.StatusOK {
+		t.Errorf("admin include_deleted: status = %d; want 200", w.Code)
+	}
+	if w := do(t, router, "PUT", "/api/posts/2", `{"title":"Ghost"}`); w.Code != http.StatusNotFound {
+		t.Errorf("update deleted post: status = %d; want 404", w.Code)
+	}
+
+	if w := do(t, router, "DELETE", "/api/users/1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("delete author of deleted post: status = %d; want 204", w.Code)
+	}
+}
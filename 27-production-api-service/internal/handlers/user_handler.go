@@ -1,138 +1,191 @@
 package handlers
 
 import (
-	"encoding/json"
+	"example.com/production-api/internal/apierror"
 	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/pagination"
+	"example.com/production-api/internal/repository"
+	"example.com/production-api/internal/services"
 	"net/http"
-	"strconv"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/go-playground/validator/v10"
-	"gorm.io/gorm"
 )
 
 // UserHandler handles user-related HTTP requests
 type UserHandler struct {
-	db       *gorm.DB
-	validate *validator.Validate
+	users *services.UserService
 }
 
 // NewUserHandler creates a new user handler with injected dependencies
-func NewUserHandler(db *gorm.DB) *UserHandler {
+func NewUserHandler(users *services.UserService) *UserHandler {
 	return &UserHandler{
-		db:       db,
-		validate: validator.New(),
+		users: users,
 	}
 }
 
-// List returns all users
+// userListSpec defines sorting and filtering accepted by List
+var userListSpec = pagination.Spec{
+	Sorts: map[string]string{
+		"id":         "id",
+		"name":       "name",
+		"email":      "email",
+		"created_at": "created_at",
+	},
+	DefaultSort: "id",
+	Filters: []pagination.Filter{
+		{Param: "email", Column: "email", Op: pagination.Equal},
+		{Param: "name", Column: "name", Op: pagination.Prefix},
+		{Param: "created_after", Column: "created_at", Op: pagination.After},
+		{Param: "created_before", Column: "created_at", Op: pagination.Before},
+	},
+}
+
+// List returns a page of users. Admins can add ?include_deleted=true.
 func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
-	var users []models.User
-	result := h.db.Find(&users)
-	if result.Error != nil {
-		respondError(w, http.StatusInternalServerError, "database error")
+	req, err := pagination.Parse(r, userListSpec)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	deleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	users, page, err := h.users.List(r.Context(), repository.UserQuery{IncludeDeleted: deleted}, req)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	page.WriteHeaders(w)
 	respondJSON(w, http.StatusOK, users)
 }
 
-// Get returns a single user
+// Get returns a single user. Its ETag lets clients revalidate with
+// If-None-Match and make conditional writes with If-Match.
 func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	id, err := parseID(r, "id")
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "invalid user ID")
+		apierror.Write(w, r, apierror.BadRequest("invalid user ID"))
 		return
 	}
 
-	var user models.User
-	result := h.db.First(&user, id)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			respondError(w, http.StatusNotFound, "user not found")
-			return
-		}
-		respondError(w, http.StatusInternalServerError, "database error")
+	deleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.Get(r.Context(), id, repository.UserQuery{IncludeDeleted: deleted})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if notModified(w, r, userETag(user)) {
 		return
 	}
 
 	respondJSON(w, http.StatusOK, user)
 }
 
+// newUserRequest holds the fields a client may set when creating a user
+type newUserRequest struct {
+	services.UserInput
+	Role string `json:"role"`
+}
+
 // Create creates a new user
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid JSON")
+	var in newUserRequest
+	if err := decodeFields(r.Body, &in); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	if err := h.validate.Struct(user); err != nil {
-		respondError(w, http.StatusBadRequest, "validation failed")
+	user := models.User{Name: in.Name, Email: in.Email, Role: in.Role}
+	if err := h.users.Create(r.Context(), &user); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	result := h.db.Create(&user)
-	if result.Error != nil {
-		respondError(w, http.StatusInternalServerError, "failed to create user")
+	w.Header().Set("ETag", userETag(&user))
+	respondJSON(w, http.StatusCreated, user)
+}
+
+// Replace replaces every writable field of a user, if it still matches
+// If-Match. Fields left out are cleared.
+func (h *UserHandler) Replace(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("invalid user ID"))
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, user)
+	var in services.UserInput
+	if err := decodeFields(r.Body, &in); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	user, err := h.users.Replace(r.Context(), id, in, precondition(r))
+	respondUser(w, r, user, err)
 }
 
-// Update updates an existing user
-func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+// Patch applies a JSON Merge Patch or JSON Patch to a user's writable
+// fields, if it still matches If-Match
+func (h *UserHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "id")
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "invalid user ID")
+		apierror.Write(w, r, apierror.BadRequest("invalid user ID"))
 		return
 	}
 
-	var user models.User
-	if result := h.db.First(&user, id); result.Error != nil {
-		respondError(w, http.StatusNotFound, "user not found")
+	mediaType, body, ok := readPatch(w, r)
+	if !ok {
 		return
 	}
 
-	var updates models.User
-	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid JSON")
+	user, err := h.users.Patch(r.Context(), id, func(current services.UserInput) (services.UserInput, error) {
+		var next services.UserInput
+		return next, applyPatch(mediaType, body, current, &next)
+	}, precondition(r))
+	respondUser(w, r, user, err)
+}
+
+// respondUser writes the result of a write to an existing user
+func respondUser(w http.ResponseWriter, r *http.Request, user *models.User, err error) {
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	h.db.Model(&user).Updates(updates)
+	w.Header().Set("ETag", userETag(user))
 	respondJSON(w, http.StatusOK, user)
 }
 
-// Delete deletes a user
-func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+// Restore undeletes a soft-deleted user
+func (h *UserHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "id")
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "invalid user ID")
+		apierror.Write(w, r, apierror.BadRequest("invalid user ID"))
 		return
 	}
 
-	result := h.db.Delete(&models.User{}, id)
-	if result.Error != nil {
-		respondError(w, http.StatusInternalServerError, "failed to delete")
+	user, err := h.users.Restore(r.Context(), id)
+	respondUser(w, r, user, err)
+}
+
+// Delete soft-deletes a user, if it still matches If-Match
+func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r, "id")
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("invalid user ID"))
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		respondError(w, http.StatusNotFound, "user not found")
+	if err := h.users.Delete(r.Context(), id, precondition(r)); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
-
-func respondJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
-}
-
-func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, map[string]string{"error": message})
-}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/audit"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/policy"
+	"example.com/production-api/internal/repository"
+	"example.com/production-api/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+)
+
+// testTokens signs access tokens for test requests
+var testTokens = newTestTokens()
+
+func newTestTokens() *auth.TokenIssuer {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			SigningKeys:     map[string]string{"test": strings.Repeat("k", 32)},
+			Issuer:          "test",
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+		},
+	}
+	tokens, err := auth.NewTokenIssuer(cfg, zerolog.Nop())
+	if err != nil {
+		panic(err)
+	}
+	return tokens
+}
+
+// newTestRouter wires the handlers to in-memory repositories
+func newTestRouter() chi.Router {
+	store := repository.NewMemoryStore()
+	entries := audit.NewMemoryStore()
+	store.Observe(entries.Record)
+	users := repository.NewMemoryUserRepository(store)
+	posts := repository.NewMemoryPostRepository(store)
+	refreshTokens := repository.NewMemoryRefreshTokenRepository(store)
+	apiKeys := services.NewAPIKeyService(repository.NewMemoryAPIKeyRepository(store), users, repository.NewMemoryTransactor())
+
+	userService := services.NewUserService(users)
+	userHandler := NewUserHandler(userService)
+	postHandler := NewPostHandler(services.NewPostService(posts, users))
+	authHandler := NewAuthHandler(services.NewAuthService(userService, users, refreshTokens, testTokens))
+	apiKeyHandler := NewAPIKeyHandler(apiKeys)
+	auditHandler := NewAuditHandler(entries)
+	pol := policy.NewEnforcer(posts)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(auth.Authenticate(testTokens, apiKeys))
+	r.Route("/api/auth", func(r chi.Router) {
+		r.Post("/register", authHandler.Register)
+		r.Post("/login", authHandler.Login)
+		r.Post("/refresh", authHandler.Refresh)
+		r.Post("/logout", authHandler.Logout)
+	})
+	postRoutes := func(r chi.Router) {
+		write := policy.RequireScope(auth.ScopePostsWrite)
+		owner := chi.Chain(pol.Require(policy.Admin, pol.PostOwner("postID")), write)
+		r.Get("/", postHandler.List)
+		r.Get("/{postID}", postHandler.Get)
+		r.With(auth.Require, write).Post("/", postHandler.Create)
+		r.With(owner...).Put("/{postID}", postHandler.Replace)
+		r.With(owner...).Patch("/{postID}", postHandler.Patch)
+		r.With(owner...).Delete("/{postID}", postHandler.Delete)
+		r.With(owner...).Post("/{postID}/publish", postHandler.Publish)
+		r.With(owner...).Post("/{postID}/unpublish", postHandler.Unpublish)
+	}
+	r.Route("/api/users", func(r chi.Router) {
+		write := policy.RequireScope(auth.ScopeUsersWrite)
+		self := chi.Chain(pol.Require(policy.Admin, policy.Self("id")), write)
+		r.With(auth.Require).Get("/", userHandler.List)
+		r.With(auth.Require).Get("/{id}", userHandler.Get)
+		r.With(pol.Require(policy.Admin), write).Post("/", userHandler.Create)
+		r.With(self...).Put("/{id}", userHandler.Replace)
+		r.With(self...).Patch("/{id}", userHandler.Patch)
+		r.With(self...).Delete("/{id}", userHandler.Delete)
+		r.With(pol.Require(policy.Admin), write).Post("/{id}/restore", userHandler.Restore)
+		r.Route("/{id}/posts", postRoutes)
+	})
+	r.Route("/api/posts", postRoutes)
+	r.Route("/api/admin/api-keys", func(r chi.Router) {
+		r.Use(pol.Require(policy.Admin), policy.RequireScope(auth.ScopeAdmin))
+		r.Get("/", apiKeyHandler.List)
+		r.Post("/", apiKeyHandler.Create)
+		r.Get("/{keyID}", apiKeyHandler.Get)
+		r.Post("/{keyID}/rotate", apiKeyHandler.Rotate)
+		r.Delete("/{keyID}", apiKeyHandler.Revoke)
+	})
+	r.Route("/api/audit", func(r chi.Router) {
+		r.Use(pol.Require(policy.Admin), policy.RequireScope(auth.ScopeAdmin))
+		r.Get("/", auditHandler.List)
+	})
+	return r
+}
+
+// tokenFor signs an access token for a user with the given role
+func tokenFor(t *testing.T, userID uint, role string) string {
+	t.Helper()
+	token, err := testTokens.Issue(auth.Principal{UserID: userID, Role: role})
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	return token
+}
+
+// do sends a request as an admin
+func do(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doWithToken(t, h, method, path, body, tokenFor(t, 1, models.RoleAdmin))
+}
+
+// doWithToken sends a request with a bearer token; an empty token sends
+// an anonymous request
+func doWithToken(t *testing.T, h http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCreateUserNormalizesEmail(t *testing.T) {
+	router := newTestRouter()
+
+	w := do(t, router, "POST", "/api/users", `{"name":"Alice","email":"  Alice@Example.COM "}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d; want 201: %s", w.Code, w.Body)
+	}
+
+	var user models.User
+	json.NewDecoder(w.Body).Decode(&user)
+	if user.Email != "alice@example.com" {
+		t.Errorf("email = %q; want alice@example.com", user.Email)
+	}
+}
+
+func TestCreateUserErrors(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   apierror.Code
+	}{
+		{"duplicate email in other case", `{"name":"Al","email":"ALICE@example.com"}`, http.StatusConflict, apierror.CodeDuplicate},
+		{"invalid email", `{"name":"Bob","email":"bob"}`, http.StatusUnprocessableEntity, apierror.CodeValidation},
+		{"malformed JSON", `{"name":`, http.StatusBadRequest, apierror.CodeInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, router, "POST", "/api/users", tt.body)
+
+			var p apierror.Problem
+			json.NewDecoder(w.Body).Decode(&p)
+			if w.Code != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("got %d %s; want %d %s", w.Code, p.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCreateRejectsServerManagedFields(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+
+	tests := []struct {
+		name  string
+		path  string
+		body  string
+		field string
+	}{
+		{"deleted user", "/api/users", `{"name":"Bob","email":"bob@example.com","deleted_at":"2024-01-01T00:00:00Z"}`, "deleted_at"},
+		{"user version", "/api/users", `{"name":"Bob","email":"bob@example.com","version":7}`, "version"},
+		{"nested posts", "/api/users", `{"name":"Bob","email":"bob@example.com","posts":[{"user_id":1,"title":"Injected"}]}`, "posts"},
+		{"post timestamps", "/api/posts", `{"user_id":1,"title":"Hello","created_at":"2001-01-01T00:00:00Z"}`, "created_at"},
+		{"deleted post", "/api/posts", `{"user_id":1,"title":"Hello","deleted_at":"2024-01-01T00:00:00Z"}`, "deleted_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, router, "POST", tt.path, tt.body)
+
+			var p apierror.Problem
+			json.NewDecoder(w.Body).Decode(&p)
+			if w.Code != http.StatusUnprocessableEntity || len(p.Errors) != 1 || p.Errors[0].Field != tt.field || p.Errors[0].Rule != "readonly" {
+				t.Errorf("got %d %+v; want 422 with %s readonly", w.Code, p.Errors, tt.field)
+			}
+		})
+	}
+
+	var posts []models.Post
+	json.NewDecoder(do(t, router, "GET", "/api/posts", "").Body).Decode(&posts)
+	if len(posts) != 0 {
+		t.Errorf("posts = %+v; want none created", posts)
+	}
+}
+
+func TestPostListFilters(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/users/1/posts", `{"title":"Draft"}`)
+	do(t, router, "POST", "/api/users/1/posts", `{"title":"Published"}`)
+	do(t, router, "POST", "/api/posts/3/publish", "")
+
+	var posts []models.Post
+	json.NewDecoder(do(t, router, "GET", "/api/posts?user_id=1&published=true", "").Body).Decode(&posts)
+	if len(posts) != 1 || posts[0].ID != 3 {
+		t.Errorf("published posts = %+v; want post 3", posts)
+	}
+
+	for _, query := range []string{"?user_id=abc", "?published=maybe"} {
+		w := do(t, router, "GET", "/api/posts"+query, "")
+		var p apierror.Problem
+		json.NewDecoder(w.Body).Decode(&p)
+		if w.Code != http.StatusBadRequest || p.Code != apierror.CodeBadRequest {
+			t.Errorf("%s: got %d %s; want 400 %s", query, w.Code, p.Code, apierror.CodeBadRequest)
+		}
+	}
+}
+
+func TestNestedPostsAreScopedToUser(t *testing.T) {
+	router := newTestRouter()
+	do(t, router, "POST", "/api/users", `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, router, "POST", "/api/users", `{"name":"Bob","email":"bob@example.com"}`)
+
+	w := do(t, router, "POST", "/api/users/1/posts", `{"title":"Hello"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create post: status = %d; want 201: %s", w.Code, w.Body)
+	}
+
+	if w := do(t, router, "GET", "/api/users/2/posts/3", ""); w.Code != http.StatusNotFound {
+		t.Errorf("other user's post: status = %d; want 404", w.Code)
+	}
+
+	w = do(t, router, "GET", "/api/users/1/posts?include=user", "")
+	var posts []models.Post
+	json.NewDecoder(w.Body).Decode(&posts)
+	if len(posts) != 1 || posts[0].User == nil || posts[0].User.Name != "Alice" {
+		t.Errorf("posts = %+v; want one post with author Alice preloaded", posts)
+	}
+
+	if w := do(t, router, "DELETE", "/api/users/1", ""); w.Code != http.StatusConflict {
+		t.Errorf("delete user with posts: status = %d; want 409", w.Code)
+	}
+}
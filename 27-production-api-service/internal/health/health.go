@@ -0,0 +1,193 @@
+// Package health serves the liveness, readiness and startup probes.
+//
+// Liveness only says the process can answer HTTP. Startup turns healthy
+// once every fx OnStart hook has run. Readiness additionally runs the
+// registered dependency checks and fails as soon as shutdown begins, so
+// load balancers stop routing to a replica before it stops serving.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/migrate"
+	"example.com/production-api/internal/repository"
+)
+
+// Statuses reported by the probes
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// CheckFunc reports whether a dependency is usable. It must give up when
+// ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Probes tracks the application's lifecycle and dependency checks
+type Probes struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []check
+
+	started  atomic.Bool
+	stopping atomic.Bool
+}
+
+// Module provides the probes and, with Postgres, checks the database
+// connection and schema
+func Module(cfg *config.Config) fx.Option {
+	if cfg.Database.Driver == repository.DriverMemory {
+		return fx.Provide(NewProbes)
+	}
+
+	return fx.Options(
+		fx.Provide(NewProbes),
+		fx.Invoke(registerDatabaseChecks),
+	)
+}
+
+// NewProbes creates probes without checks; the application is neither
+// started nor ready until Started is called
+func NewProbes(cfg *config.Config) *Probes {
+	return &Probes{timeout: cfg.Health.Timeout}
+}
+
+func registerDatabaseChecks(p *Probes, db *gorm.DB, migrator *migrate.Runner) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database instance: %w", err)
+	}
+	p.Register("database", sqlDB.PingContext)
+	p.Register("migrations", migrator.Check)
+	return nil
+}
+
+// Register adds a readiness check
+func (p *Probes) Register(name string, fn CheckFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checks = append(p.checks, check{name: name, fn: fn})
+}
+
+// Started marks startup as complete
+func (p *Probes) Started() {
+	p.started.Store(true)
+}
+
+// Stopping makes readiness fail from now on
+func (p *Probes) Stopping() {
+	p.stopping.Store(true)
+}
+
+// CheckResult is the outcome of one readiness check
+type CheckResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the body of every probe response
+type Report struct {
+	Status string `json:"status"`
+	// Reason explains an unavailable status that no check caused
+	Reason string                 `json:"reason,omitempty"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Ready runs every check concurrently, each bounded by the configured
+// timeout, and reports the application ready if all of them pass
+func (p *Probes) Ready(ctx context.Context) Report {
+	switch {
+	case p.stopping.Load():
+		return Report{Status: StatusUnavailable, Reason: "shutting down"}
+	case !p.started.Load():
+		return Report{Status: StatusUnavailable, Reason: "starting"}
+	}
+
+	p.mu.RLock()
+	checks := append([]check(nil), p.checks...)
+	p.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = p.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
+}
+
+func (p *Probes) run(ctx context.Context, c check) CheckResult {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := c.fn(ctx)
+	result := CheckResult{Status: StatusOK, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Live answers every request with 200 while the process can serve HTTP
+func (p *Probes) Live(w http.ResponseWriter, r *http.Request) {
+	write(w, Report{Status: StatusOK})
+}
+
+// Startup answers 503 until every OnStart hook has run
+func (p *Probes) Startup(w http.ResponseWriter, r *http.Request) {
+	if !p.started.Load() {
+		write(w, Report{Status: StatusUnavailable, Reason: "starting"})
+		return
+	}
+	write(w, Report{Status: StatusOK})
+}
+
+// Readiness answers 503 with a per-check breakdown unless Ready passes
+func (p *Probes) Readiness(w http.ResponseWriter, r *http.Request) {
+	write(w, p.Ready(r.Context()))
+}
+
+func write(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example.com/production-api/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func probe(t *testing.T, h http.HandlerFunc) (int, Report) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/", nil))
+
+	var report Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return w.Code, report
+}
+
+func TestLifecycle(t *testing.T) {
+	p := NewProbes(&config.Config{})
+
+	if code, _ := probe(t, p.Live); code != http.StatusOK {
+		t.Errorf("live while starting = %d; want 200", code)
+	}
+	if code, _ := probe(t, p.Startup); code != http.StatusServiceUnavailable {
+		t.Errorf("startup while starting = %d; want 503", code)
+	}
+	if code, r := probe(t, p.Readiness); code != http.StatusServiceUnavailable || r.Reason != "starting" {
+		t.Errorf("ready while starting = %d %+v; want 503 starting", code, r)
+	}
+
+	p.Started()
+	if code, _ := probe(t, p.Startup); code != http.StatusOK {
+		t.Errorf("startup = %d; want 200", code)
+	}
+	if code, _ := probe(t, p.Readiness); code != http.StatusOK {
+		t.Errorf("ready = %d; want 200", code)
+	}
+
+	p.Stopping()
+	if code, r := probe(t, p.Readiness); code != http.StatusServiceUnavailable || r.Reason != "shutting down" {
+		t.Errorf("ready while stopping = %d %+v; want 503 shutting down", code, r)
+	}
+	if code, _ := probe(t, p.Live); code != http.StatusOK {
+		t.Errorf("live while stopping = %d; want 200", code)
+	}
+}
+
+func TestReadinessChecks(t *testing.T) {
+	p := NewProbes(&config.Config{Health: config.HealthConfig{Timeout: 20 * time.Millisecond}})
+	p.Register("database", func(ctx context.Context) error { return nil })
+	p.Register("migrations", func(ctx context.Context) error { return errors.New("schema has pending migrations") })
+	p.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	p.Started()
+
+	start := time.Now()
+	code, r := probe(t, p.Readiness)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("readiness took %v; checks should time out after 20ms", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || r.Status != StatusUnavailable {
+		t.Fatalf("ready = %d %s; want 503 unavailable", code, r.Status)
+	}
+
+	want := map[string]string{"database": StatusOK, "migrations": StatusUnavailable, "slow": StatusUnavailable}
+	for name, status := range want {
+		if got := r.Checks[name]; got.Status != status {
+			t.Errorf("%s = %+v; want %s", name, got, status)
+		}
+	}
+	if got := r.Checks["migrations"].Error; got != "schema has pending migrations" {
+		t.Errorf("migrations error = %q", got)
+	}
+	if got := r.Checks["slow"].Error; got != context.DeadlineExceeded.Error() {
+		t.Errorf("slow error = %q; want deadline exceeded", got)
+	}
+}
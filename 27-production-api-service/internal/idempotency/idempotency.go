@@ -0,0 +1,113 @@
+// Package idempotency makes POST requests safe to retry. A client sends
+// an Idempotency-Key header; the first request with a key runs normally
+// and its response is stored, later requests with the same key and
+// payload get the stored response back instead of running again.
+package idempotency
+
+import (
+	"context"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/repository"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// Header is the request header carrying the key
+const Header = "Idempotency-Key"
+
+// ReplayedHeader marks responses served from a stored record
+const ReplayedHeader = "Idempotent-Replayed"
+
+// lockTimeout is how long an unfinished request holds its key. After
+// that a retry may run again, e.g. when a replica died mid-request.
+const lockTimeout = time.Minute
+
+// Record is a stored request and, once finished, its response
+type Record struct {
+	Key         string
+	Fingerprint string
+	// Status is zero while the first request is still running
+	Status    int
+	Header    http.Header
+	Body      []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Done reports whether the response has been stored
+func (r *Record) Done() bool {
+	return r.Status != 0
+}
+
+// claimable reports whether a new request may take over r: it expired or
+// its request died without finishing
+func (r *Record) claimable(now time.Time) bool {
+	return !now.Before(r.ExpiresAt) || (!r.Done() && now.Sub(r.CreatedAt) >= lockTimeout)
+}
+
+// Store keeps idempotency records
+type Store interface {
+	// Claim stores rec for a new request. If another live record holds
+	// the key, it is returned instead and rec is not stored.
+	Claim(ctx context.Context, rec *Record, now time.Time) (*Record, error)
+	// Complete stores the final response of a claimed record
+	Complete(ctx context.Context, rec *Record) error
+	// Release deletes a claimed record so the request can be retried
+	Release(ctx context.Context, key string) error
+	// DeleteExpired removes records that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Module provides the middleware with a store matching the database
+// driver and schedules the cleanup of expired records
+func Module(cfg *config.Config) fx.Option {
+	store := fx.Provide(NewPostgresStore)
+	if cfg.Database.Driver == repository.DriverMemory {
+		store = fx.Provide(func() Store { return NewMemoryStore() })
+	}
+
+	return fx.Options(
+		store,
+		fx.Provide(NewMiddleware),
+		fx.Invoke(scheduleCleanup),
+	)
+}
+
+func scheduleCleanup(lc fx.Lifecycle, cfg *config.Config, store Store, logger zerolog.Logger) {
+	interval := cfg.Idempotency.CleanupInterval
+	if interval <= 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-done:
+						return
+					case now := <-ticker.C:
+						n, err := store.DeleteExpired(context.Background(), now)
+						if err != nil {
+							logger.Error().Err(err).Msg("Failed to delete expired idempotency records")
+							continue
+						}
+						logger.Debug().Int64("deleted", n).Msg("Deleted expired idempotency records")
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(done)
+			return nil
+		},
+	})
+}
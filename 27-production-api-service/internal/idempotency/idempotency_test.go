@@ -0,0 +1,185 @@
+package idempotency
+
+import (
+	"context"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/config"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// counting is a handler that creates a numbered resource per call
+type counting struct {
+	calls  int
+	status int
+}
+
+func (c *counting) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.calls++
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-RateLimit-Remaining", "9")
+	w.WriteHeader(c.status)
+	fmt.Fprintf(w, `{"id":%d}`, c.calls)
+}
+
+func newTestMiddleware() (*Middleware, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMiddleware(&config.Config{Idempotency: config.IdempotencyConfig{TTL: time.Hour}}, NewMemoryStore())
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func post(h http.Handler, key, body string, p *auth.Principal) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/api/users", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	if p != nil {
+		r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRetryReplaysStoredResponse(t *testing.T) {
+	m, _ := newTestMiddleware()
+	next := &counting{status: http.StatusCreated}
+	h := m.Handler(next)
+
+	first := post(h, "abc", `{"name":"Alice"}`, nil)
+	retry := post(h, "abc", `{"name":"Alice"}`, nil)
+
+	if next.calls != 1 {
+		t.Fatalf("handler ran %d times; want 1", next.calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s; want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(ReplayedHeader) != "true" {
+		t.Error("replayed response is not marked")
+	}
+	if retry.Header().Get("X-RateLimit-Remaining") != "" {
+		t.Error("per-request header was replayed")
+	}
+
+	if post(h, "", `{"name":"Alice"}`, nil); next.calls != 2 {
+		t.Error("request without a key was not passed through")
+	}
+}
+
+func TestKeyReusedWithDifferentPayload(t *testing.T) {
+	m, _ := newTestMiddleware()
+	h := m.Handler(&counting{status: http.StatusCreated})
+
+	post(h, "abc", `{"name":"Alice"}`, nil)
+	if w := post(h, "abc", `{"name":"Bob"}`, nil); w.Code != http.StatusConflict ||
+		!strings.Contains(w.Body.String(), "idempotency_key_mismatch") {
+		t.Errorf("different payload: %d %s; want 409 idempotency_key_mismatch", w.Code, w.Body)
+	}
+}
+
+func TestKeysAreScopedToCaller(t *testing.T) {
+	m, _ := newTestMiddleware()
+	next := &counting{status: http.StatusCreated}
+	h := m.Handler(next)
+
+	post(h, "abc", `{}`, &auth.Principal{UserID: 1})
+	post(h, "abc", `{}`, &auth.Principal{UserID: 2})
+	if next.calls != 2 {
+		t.Errorf("handler ran %d times; want once per caller", next.calls)
+	}
+}
+
+func TestServerErrorsAreNotStored(t *testing.T) {
+	m, _ := newTestMiddleware()
+	next := &counting{status: http.StatusInternalServerError}
+	h := m.Handler(next)
+
+	post(h, "abc", `{}`, nil)
+	next.status = http.StatusCreated
+	if w := post(h, "abc", `{}`, nil); w.Code != http.StatusCreated || next.calls != 2 {
+		t.Errorf("retry after 500: status %d after %d calls; want 201 after 2", w.Code, next.calls)
+	}
+}
+
+func TestRecordsExpire(t *testing.T) {
+	m, now := newTestMiddleware()
+	next := &counting{status: http.StatusCreated}
+	h := m.Handler(next)
+
+	post(h, "abc", `{}`, nil)
+	*now = now.Add(2 * time.Hour)
+
+	if n, _ := m.store.DeleteExpired(context.Background(), *now); n != 1 {
+		t.Errorf("DeleteExpired removed %d records; want 1", n)
+	}
+	if post(h, "abc", `{}`, nil); next.calls != 2 {
+		t.Error("expired key was replayed")
+	}
+}
+
+func TestConcurrentRetryWhileInProgress(t *testing.T) {
+	m, _ := newTestMiddleware()
+
+	var retry *httptest.ResponseRecorder
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The client gives up and retries before the first request ends
+		retry = post(m.Handler(&counting{status: http.StatusCreated}), "abc", `{}`, nil)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	post(h, "abc", `{}`, nil)
+	if retry.Code != http.StatusConflict || retry.Header().Get("Retry-After") == "" {
+		t.Errorf("retry during first request: %d %v; want 409 with Retry-After", retry.Code, retry.Header())
+	}
+}
+
+// ctxStore fails writes on a cancelled context, as a database would
+type ctxStore struct{ Store }
+
+func (s ctxStore) Complete(ctx context.Context, rec *Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.Complete(ctx, rec)
+}
+
+func (s ctxStore) Release(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.Release(ctx, key)
+}
+
+func TestClientDisconnectStillStoresResponse(t *testing.T) {
+	for _, status := range []int{http.StatusCreated, http.StatusInternalServerError} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			m, _ := newTestMiddleware()
+			m.store = ctxStore{m.store}
+			next := &counting{status: status}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			r := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{}`)).WithContext(ctx)
+			r.Header.Set(Header, "abc")
+			m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r)
+				// The client disconnects before the handler returns
+				cancel()
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			next.status = http.StatusCreated
+			retry := post(m.Handler(next), "abc", `{}`, nil)
+			switch {
+			case status == http.StatusCreated && (next.calls != 1 || retry.Header().Get(ReplayedHeader) != "true"):
+				t.Errorf("retry: %d %s after %d calls; want the stored response", retry.Code, retry.Body, next.calls)
+			case status != http.StatusCreated && (next.calls != 2 || retry.Code != http.StatusCreated):
+				t.Errorf("retry after 500: %d after %d calls; want 201 after 2", retry.Code, next.calls)
+			}
+		})
+	}
+}
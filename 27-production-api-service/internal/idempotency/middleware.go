@@ -0,0 +1,188 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/logging"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxKeyLength bounds the Idempotency-Key header
+	maxKeyLength = 255
+	// maxBody bounds the request body read for the fingerprint
+	maxBody = 1 << 20
+	// saveTimeout bounds storing the outcome of a request, which happens
+	// even when the client has gone away
+	saveTimeout = 5 * time.Second
+)
+
+// replayHeaders are the response headers stored and replayed with the
+// body. Per-request headers such as rate limits are not replayed.
+var replayHeaders = []string{"Content-Type", "Location", "ETag", "Cache-Control"}
+
+// Middleware replays stored responses for repeated Idempotency-Key
+// requests
+type Middleware struct {
+	store Store
+	ttl   time.Duration
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewMiddleware creates the middleware
+func NewMiddleware(cfg *config.Config, store Store) *Middleware {
+	return &Middleware{
+		store: store,
+		ttl:   cfg.Idempotency.TTL,
+		now:   time.Now,
+	}
+}
+
+// Handler applies idempotency to POST requests that carry the header.
+// Keys are scoped to the caller, so clients can't see each other's
+// responses. It must run after auth.Authenticate.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			apierror.Write(w, r, apierror.BadRequest(Header+" must be at most 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil {
+			apierror.Write(w, r, apierror.UnreadableBody(err))
+			return
+		}
+		if len(body) > maxBody {
+			apierror.Write(w, r, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeTooLarge,
+				"request body is too large for an idempotent request"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := m.now()
+		rec := &Record{
+			Key:         scope(r) + "|" + key,
+			Fingerprint: fingerprint(r, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(m.ttl),
+		}
+
+		existing, err := m.store.Claim(r.Context(), rec, now)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+		if existing != nil {
+			m.replay(w, r, existing, rec.Fingerprint)
+			return
+		}
+
+		rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		// A client that disconnected cancels the request context, and is
+		// the one most likely to retry: the outcome must be stored anyway,
+		// or the retry claims the key again once the lock expires
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), saveTimeout)
+		defer cancel()
+
+		// Server errors are not final: let the client retry them
+		if rw.status >= http.StatusInternalServerError {
+			if err := m.store.Release(ctx, rec.Key); err != nil {
+				logging.FromContext(r.Context()).Error().Err(err).Msg("Failed to release idempotency key")
+			}
+			return
+		}
+
+		rec.Status = rw.status
+		rec.Header = make(http.Header)
+		for _, h := range replayHeaders {
+			if v := rw.Header().Values(h); len(v) > 0 {
+				rec.Header[h] = v
+			}
+		}
+		rec.Body = rw.body.Bytes()
+		if err := m.store.Complete(ctx, rec); err != nil {
+			logging.FromContext(r.Context()).Error().Err(err).Msg("Failed to store idempotent response")
+		}
+	})
+}
+
+// replay answers a request whose key is already taken
+func (m *Middleware) replay(w http.ResponseWriter, r *http.Request, rec *Record, fp string) {
+	switch {
+	case rec.Fingerprint != fp:
+		apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeIdempotencyMismatch,
+			Header+" was already used with a different request"))
+	case !rec.Done():
+		w.Header().Set("Retry-After", "1")
+		apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeInProgress,
+			"a request with this "+Header+" is still in progress"))
+	default:
+		for h, v := range rec.Header {
+			w.Header()[h] = v
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(rec.Status)
+		w.Write(rec.Body)
+	}
+}
+
+// scope identifies the caller a key belongs to
+func scope(r *http.Request) string {
+	p := auth.PrincipalFrom(r.Context())
+	switch {
+	case p == nil:
+		return "anonymous"
+	case p.APIKeyID != 0:
+		return "key:" + strconv.FormatUint(uint64(p.APIKeyID), 10)
+	default:
+		return "user:" + strconv.FormatUint(uint64(p.UserID), 10)
+	}
+}
+
+// fingerprint identifies the request payload a key was first used with
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder passes the response through while keeping a copy
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,104 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"example.com/production-api/internal/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// recordRow is a row of idempotency_keys
+type recordRow struct {
+	Key         string `gorm:"primaryKey"`
+	Fingerprint string
+	Status      int
+	Header      string
+	Body        []byte
+	CreatedAt   time.Time `gorm:"autoCreateTime:false"`
+	ExpiresAt   time.Time
+}
+
+func (recordRow) TableName() string {
+	return "idempotency_keys"
+}
+
+// PostgresStore keeps records in the idempotency_keys table, so a retry
+// landing on another replica is still recognised
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a Postgres-backed store
+func NewPostgresStore(db *gorm.DB) Store {
+	return &PostgresStore{db: db}
+}
+
+// Claim implements Store. The insert only overwrites an existing row if
+// that row may be taken over, so exactly one concurrent request wins.
+func (s *PostgresStore) Claim(ctx context.Context, rec *Record, now time.Time) (*Record, error) {
+	result := s.db.WithContext(ctx).Exec(`
+		INSERT INTO idempotency_keys (key, fingerprint, status, header, body, created_at, expires_at)
+		VALUES (?, ?, 0, '', NULL, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status = 0,
+			header = '',
+			body = NULL,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= ?
+			OR (idempotency_keys.status = 0 AND idempotency_keys.created_at <= ?)`,
+		rec.Key, rec.Fingerprint, rec.CreatedAt, rec.ExpiresAt, now, now.Add(-lockTimeout))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	// The row the insert conflicted with may not have reached a replica
+	var row recordRow
+	if err := s.db.WithContext(database.Primary(ctx)).Where("key = ?", rec.Key).Take(&row).Error; err != nil {
+		return nil, err
+	}
+	existing := &Record{
+		Key:         row.Key,
+		Fingerprint: row.Fingerprint,
+		Status:      row.Status,
+		Body:        row.Body,
+		CreatedAt:   row.CreatedAt,
+		ExpiresAt:   row.ExpiresAt,
+	}
+	if row.Header != "" {
+		if err := json.Unmarshal([]byte(row.Header), &existing.Header); err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+// Complete implements Store
+func (s *PostgresStore) Complete(ctx context.Context, rec *Record) error {
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Model(&recordRow{}).Where("key = ?", rec.Key).Updates(map[string]interface{}{
+		"status": rec.Status,
+		"header": string(header),
+		"body":   rec.Body,
+	}).Error
+}
+
+// Release implements Store
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&recordRow{}).Error
+}
+
+// DeleteExpired implements Store
+func (s *PostgresStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&recordRow{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger bridges GORM's logger interface into zerolog.
+// Queries are logged through the request-scoped logger when the GORM
+// session carries a request context (db.WithContext(r.Context())).
+type GormLogger struct {
+	logger        zerolog.Logger
+	logLevel      *Level
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+}
+
+// NewGormLogger creates a GORM logger for a logger created by New with
+// logLevel. Queries slower than slowThreshold are logged as warnings; a
+// zero threshold disables slow-query logging.
+func NewGormLogger(logger zerolog.Logger, logLevel *Level, slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{
+		logger:        logger.With().Str("component", "gorm").Logger(),
+		logLevel:      logLevel,
+		level:         gormlogger.Info,
+		slowThreshold: slowThreshold,
+	}
+}
+
+// LogMode returns a copy of the logger with the given level
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+// Info logs an informational message
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.from(ctx).Info().Msg(fmt.Sprintf(msg, args...))
+	}
+}
+
+// Warn logs a warning message
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.from(ctx).Warn().Msg(fmt.Sprintf(msg, args...))
+	}
+}
+
+// Error logs an error message
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.from(ctx).Error().Msg(fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace logs a completed SQL statement. Failed queries are logged as
+// errors, slow queries as warnings and everything else at debug level.
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+
+	var level zerolog.Level
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		level = zerolog.ErrorLevel
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		level = zerolog.WarnLevel
+	case l.level >= gormlogger.Info:
+		level = zerolog.DebugLevel
+	default:
+		return
+	}
+
+	// Every statement passes through here: skip building the SQL for
+	// events the writer would drop
+	if !l.logLevel.Enabled(level) {
+		return
+	}
+
+	event := l.from(ctx).WithLevel(level)
+	switch level {
+	case zerolog.ErrorLevel:
+		event = event.Err(err)
+	case zerolog.WarnLevel:
+		event = event.Dur("threshold", l.slowThreshold).Bool("slow", true)
+	}
+
+	sql, rows := fc()
+	event.
+		Str("sql", sql).
+		Int64("rows", rows).
+		Dur("elapsed", elapsed).
+		Msg("SQL query")
+}
+
+// from prefers the request-scoped logger from ctx over the base logger
+func (l *GormLogger) from(ctx context.Context) *zerolog.Logger {
+	if ctx != nil {
+		if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+			child := logger.With().Str("component", "gorm").Logger()
+			return &child
+		}
+	}
+	return &l.logger
+}
@@ -0,0 +1,125 @@
+// Package logging provides structured logging built on zerolog
+package logging
+
+import (
+	"context"
+	"example.com/production-api/internal/config"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// Module provides logging dependencies
+var Module = fx.Options(
+	fx.Provide(New),
+)
+
+// New creates the root logger from application configuration.
+// Development environments get human-readable console output,
+// everything else writes JSON; both go to stdout.
+//
+// The configured level belongs to the returned logger and the loggers
+// derived from it, never to zerolog's global level, so other loggers in
+// the process keep theirs. The returned Level changes it at runtime.
+func New(cfg *config.Config) (zerolog.Logger, *Level, error) {
+	level, err := parseLevel(cfg.App.LogLevel)
+	if err != nil {
+		return zerolog.Nop(), nil, err
+	}
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+
+	var out io.Writer = os.Stdout
+	if cfg.App.Environment == "development" {
+		out = zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	}
+
+	lvl := &Level{}
+	lvl.Set(level)
+	logger := newLogger(out, lvl).With().
+		Timestamp().
+		Str("app", cfg.App.Name).
+		Str("env", cfg.App.Environment).
+		Logger()
+
+	return logger, lvl, nil
+}
+
+// newLogger returns a logger writing to out at lvl
+func newLogger(out io.Writer, lvl *Level) zerolog.Logger {
+	// Derived loggers copy Logger.Level, so it stays at the lowest level
+	// and the writer drops what is below lvl at the time of the event
+	return zerolog.New(levelWriter{out: out, level: lvl}).Level(zerolog.TraceLevel)
+}
+
+// Level is the minimum level of a logger created by New. Unlike
+// Logger.Level it can change while derived loggers are in use.
+type Level struct {
+	level atomic.Int32
+}
+
+// Get returns the current level
+func (l *Level) Get() zerolog.Level {
+	return zerolog.Level(l.level.Load())
+}
+
+// Enabled reports whether events at level are written. Event.Enabled is
+// always true on loggers created by New, so callers check this instead
+// before building costly fields.
+func (l *Level) Enabled(level zerolog.Level) bool {
+	return level >= l.Get()
+}
+
+// Set changes the level
+func (l *Level) Set(level zerolog.Level) {
+	l.level.Store(int32(level))
+}
+
+// Apply changes the level when a config reload changed app.loglevel
+func (l *Level) Apply(change config.Change) {
+	if !change.Changed("app.loglevel") {
+		return
+	}
+	if level, err := parseLevel(change.New.App.LogLevel); err == nil {
+		l.Set(level)
+	}
+}
+
+// levelWriter drops events below level
+type levelWriter struct {
+	out   io.Writer
+	level *Level
+}
+
+func (w levelWriter) Write(p []byte) (int, error) {
+	return w.out.Write(p)
+}
+
+func (w levelWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if !w.level.Enabled(level) {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+func parseLevel(s string) (zerolog.Level, error) {
+	level, err := zerolog.ParseLevel(s)
+	if err != nil {
+		return zerolog.NoLevel, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+	if level == zerolog.NoLevel {
+		level = zerolog.InfoLevel
+	}
+	return level, nil
+}
+
+// FromContext returns the request-scoped logger stored in ctx.
+// If none is present, a disabled logger is returned so callers never
+// need to nil-check.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	return zerolog.Ctx(ctx)
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"example.com/production-api/internal/config"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLevelApply(t *testing.T) {
+	global := zerolog.GlobalLevel()
+	var buf bytes.Buffer
+	lvl := &Level{}
+	lvl.Set(zerolog.InfoLevel)
+	logger := newLogger(&buf, lvl).With().Str("app", "test").Logger()
+
+	logger.Debug().Msg("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("debug logged at info level: %s", buf.String())
+	}
+
+	next := &config.Config{App: config.AppConfig{LogLevel: "debug"}}
+	lvl.Apply(config.Change{New: next, Keys: []string{"app.loglevel"}})
+	logger.Debug().Msg("shown")
+	if !bytes.Contains(buf.Bytes(), []byte("shown")) {
+		t.Errorf("derived logger ignored the reloaded level: %q", buf.String())
+	}
+	if zerolog.GlobalLevel() != global {
+		t.Errorf("global level changed to %s", zerolog.GlobalLevel())
+	}
+
+	// Unrelated changes keep the level
+	lvl.Apply(config.Change{New: &config.Config{}, Keys: []string{"cors.allowedorigins"}})
+	if lvl.Get() != zerolog.DebugLevel {
+		t.Errorf("level = %s; want debug", lvl.Get())
+	}
+}
+
+func TestGormTraceSkipsFilteredStatements(t *testing.T) {
+	var buf bytes.Buffer
+	lvl := &Level{}
+	lvl.Set(zerolog.InfoLevel)
+	gl := NewGormLogger(newLogger(&buf, lvl), lvl, time.Second)
+
+	built := 0
+	fc := func() (string, int64) {
+		built++
+		return "SELECT 1", 1
+	}
+
+	gl.Trace(context.Background(), time.Now(), fc, nil)
+	if built != 0 || buf.Len() != 0 {
+		t.Errorf("info level: SQL built %d times, logged %q; want neither", built, buf.String())
+	}
+
+	gl.Trace(context.Background(), time.Now(), fc, errors.New("boom"))
+	if built != 1 || !bytes.Contains(buf.Bytes(), []byte("boom")) {
+		t.Errorf("failed query: SQL built %d times, logged %q; want it logged", built, buf.String())
+	}
+
+	lvl.Set(zerolog.DebugLevel)
+	gl.Trace(context.Background(), time.Now(), fc, nil)
+	if built != 2 || !bytes.Contains(buf.Bytes(), []byte(`"level":"debug"`)) {
+		t.Errorf("debug level: SQL built %d times, logged %q; want the statement", built, buf.String())
+	}
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware attaches a child logger carrying the chi request ID and, when
+// the request is traced, its trace and span IDs to every request context
+// and writes one structured access log entry per request. It must be
+// registered after middleware.RequestID, middleware.RealIP and the
+// tracing middleware.
+func Middleware(logger zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			logCtx := logger.With().
+				Str("request_id", middleware.GetReqID(r.Context()))
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				logCtx = logCtx.
+					Str("trace_id", sc.TraceID().String()).
+					Str("span_id", sc.SpanID().String())
+			}
+			reqLogger := logCtx.Logger()
+			ctx := reqLogger.WithContext(r.Context())
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			event := reqLogger.Info()
+			switch {
+			case status >= http.StatusInternalServerError:
+				event = reqLogger.Error()
+			case status >= http.StatusBadRequest:
+				event = reqLogger.Warn()
+			}
+
+			event.
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("query", r.URL.RawQuery).
+				Int("status", status).
+				Int("bytes", ww.BytesWritten()).
+				Dur("duration", time.Since(start)).
+				Str("remote_ip", r.RemoteAddr).
+				Str("user_agent", r.UserAgent()).
+				Msg("Request completed")
+		})
+	}
+}
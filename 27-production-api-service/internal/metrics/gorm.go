@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/database"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+// startKey holds the time a statement started
+const startKey = "metrics:start"
+
+// InstrumentDB times every GORM statement and exports the statistics of
+// each connection pool, labelled with the pool's name
+func InstrumentDB(cfg *config.Config, db *gorm.DB, pools database.Pools, m *Metrics) error {
+	for _, p := range pools {
+		reg := prometheus.WrapRegistererWith(prometheus.Labels{"pool": p.Name}, m.registry)
+		if err := reg.Register(collectors.NewDBStatsCollector(p.DB, cfg.Database.DBName)); err != nil {
+			return err
+		}
+	}
+
+	start := func(tx *gorm.DB) {
+		tx.InstanceSet(startKey, time.Now())
+	}
+	observe := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if begin, ok := tx.InstanceGet(startKey); ok {
+				m.queries.WithLabelValues(operation, tx.Statement.Table).Observe(time.Since(begin.(time.Time)).Seconds())
+			}
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("*").Register("metrics:before_create", start),
+		cb.Create().After("*").Register("metrics:after_create", observe("create")),
+		cb.Query().Before("*").Register("metrics:before_query", start),
+		cb.Query().After("*").Register("metrics:after_query", observe("query")),
+		cb.Update().Before("*").Register("metrics:before_update", start),
+		cb.Update().After("*").Register("metrics:after_update", observe("update")),
+		cb.Delete().Before("*").Register("metrics:before_delete", start),
+		cb.Delete().After("*").Register("metrics:after_delete", observe("delete")),
+		cb.Row().Before("*").Register("metrics:before_row", start),
+		cb.Row().After("*").Register("metrics:after_row", observe("row")),
+		cb.Raw().Before("*").Register("metrics:before_raw", start),
+		cb.Raw().After("*").Register("metrics:after_raw", observe("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
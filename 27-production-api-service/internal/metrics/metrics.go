@@ -0,0 +1,152 @@
+// Package metrics exposes Prometheus metrics on a separate admin listener:
+// HTTP request counts and latencies by route pattern, database pool and
+// query timings, and the build the process is running.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/repository"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// namespace prefixes every application metric
+const namespace = "api"
+
+// Version is the release the binary was built from. The Makefile sets it
+// with -ldflags "-X example.com/production-api/internal/metrics.Version=...".
+var Version = "dev"
+
+// Metrics holds the application's collectors. When disabled in config
+// its middleware and instrumentation do nothing.
+type Metrics struct {
+	enabled  bool
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	queries  *prometheus.HistogramVec
+}
+
+// Module provides Metrics and, when metrics.enabled is set, serves them
+// on the admin port and instruments the database
+func Module(cfg *config.Config) fx.Option {
+	if !cfg.Metrics.Enabled {
+		return fx.Provide(New)
+	}
+
+	opts := []fx.Option{
+		fx.Provide(New),
+		fx.Invoke(registerServer),
+	}
+	if cfg.Database.Driver != repository.DriverMemory {
+		opts = append(opts, fx.Invoke(InstrumentDB))
+	}
+	return fx.Options(opts...)
+}
+
+// New creates the collectors and registers them with a private registry
+func New(cfg *config.Config) *Metrics {
+	m := &Metrics{
+		enabled:  cfg.Metrics.Enabled,
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "GORM statement duration by operation and table.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		buildInfo(),
+		m.requests,
+		m.latency,
+		m.queries,
+	)
+	return m
+}
+
+// Handler serves the registered metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// buildInfo is a gauge that is always 1 and carries the build as labels
+func buildInfo() prometheus.Collector {
+	revision, goVersion := "unknown", "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		goVersion = info.GoVersion
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				revision = s.Value
+			}
+		}
+	}
+
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Build information of the running binary; always 1.",
+		ConstLabels: prometheus.Labels{
+			"version":    Version,
+			"revision":   revision,
+			"go_version": goVersion,
+		},
+	})
+	g.Set(1)
+	return g
+}
+
+// registerServer serves /metrics on the admin port. It is kept apart
+// from the public API so it can be firewalled off.
+func registerServer(lc fx.Lifecycle, cfg *config.Config, m *Metrics, logger zerolog.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	srv := &http.Server{
+		Addr:              ":" + cfg.Metrics.Port,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			logger.Info().
+				Str("port", cfg.Metrics.Port).
+				Msg("Starting metrics server")
+
+			go func() {
+				if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logger.Error().Err(err).Msg("Metrics server error")
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			logger.Info().Msg("Stopping metrics server")
+			return srv.Shutdown(ctx)
+		},
+	})
+}
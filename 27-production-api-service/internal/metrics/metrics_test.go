@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"database/sql"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newTestRouter(m *Metrics) chi.Router {
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			if chi.URLParam(r, "id") == "404" {
+				http.NotFound(w, r)
+			}
+		})
+	})
+	return r
+}
+
+func TestMiddlewareLabelsRoutePattern(t *testing.T) {
+	m := New(&config.Config{Metrics: config.MetricsConfig{Enabled: true}})
+	router := newTestRouter(m)
+	for _, path := range []string{"/api/users/1", "/api/users/2", "/api/users/404", "/api/nope", "/wp-login.php"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	tests := []struct {
+		route, status string
+		want          float64
+	}{
+		{"/api/users/{id}", "200", 2},
+		{"/api/users/{id}", "404", 1},
+		{unmatched, "404", 2},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", tt.route, tt.status)); got != tt.want {
+			t.Errorf("requests{route=%q,status=%s} = %v; want %v", tt.route, tt.status, got, tt.want)
+		}
+	}
+	if n := testutil.CollectAndCount(m.latency); n != len(tests) {
+		t.Errorf("latency series = %d; want %d", n, len(tests))
+	}
+}
+
+func TestHandlerExposesBuildInfo(t *testing.T) {
+	m := New(&config.Config{Metrics: config.MetricsConfig{Enabled: true}})
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if body := w.Body.String(); !strings.Contains(body, `api_build_info{go_version="`) || !strings.Contains(body, `version="dev"} 1`) {
+		t.Errorf("build info missing from:\n%s", body)
+	}
+}
+
+func TestDisabledMiddlewarePassesThrough(t *testing.T) {
+	m := New(&config.Config{})
+	newTestRouter(m).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users/1", nil))
+
+	if n := testutil.CollectAndCount(m.requests); n != 0 {
+		t.Errorf("disabled metrics recorded %d series", n)
+	}
+}
+
+func TestInstrumentDBExportsEveryPool(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pools := database.Pools{{Name: "primary"}, {Name: "replica db-2:5432"}}
+	for i := range pools {
+		// Opening does not connect; the stats are those of an idle pool
+		if pools[i].DB, err = sql.Open("pgx", "host=localhost"); err != nil {
+			t.Fatal(err)
+		}
+		defer pools[i].DB.Close()
+	}
+
+	cfg := &config.Config{Metrics: config.MetricsConfig{Enabled: true}, Database: config.DatabaseConfig{DBName: "api"}}
+	m := New(cfg)
+	if err := InstrumentDB(cfg, db, pools, m); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, series := range []string{
+		`go_sql_open_connections{db_name="api",pool="primary"} 0`,
+		`go_sql_open_connections{db_name="api",pool="replica db-2:5432"} 0`,
+	} {
+		if !strings.Contains(body, series) {
+			t.Errorf("%s missing from:\n%s", series, body)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatched labels requests that matched no route, so scanners probing
+// random paths cannot blow up the label set. Subrouters report those as
+// their mount pattern, e.g. /api/*.
+const unmatched = "unmatched"
+
+// Middleware counts and times requests. Requests are labelled with the
+// chi route pattern, such as /api/users/{id}, rather than the raw path.
+// It must be registered on the root router.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if !m.enabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		// The router fills in the pattern while routing, so it is only
+		// known once the request has been served
+		route := unmatched
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" && !strings.HasSuffix(pattern, "/*") {
+				route = pattern
+			}
+		}
+
+		labels := []string{r.Method, route, strconv.Itoa(status)}
+		m.requests.WithLabelValues(labels...).Inc()
+		m.latency.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,372 @@
+// Package migrate applies the embedded SQL migrations and records them in
+// the schema_migrations table.
+//
+// Every run holds a Postgres advisory lock, so replicas starting at the
+// same time apply each migration exactly once. Each script runs in one
+// transaction with the schema_migrations row that records it; DDL in
+// Postgres is transactional, so a failed or interrupted migration leaves
+// neither the schema change nor the row behind and simply runs again.
+//
+// Rows flagged dirty were written by earlier versions, which marked a
+// migration before running its script. Such a script never committed;
+// Force discards the row so the migration runs again.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// lockKey identifies the migration advisory lock. Any constant works as
+// long as no other code in the database uses the same key.
+const lockKey int64 = 0x6d6967726174 // "migrat"
+
+const createTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	checksum   TEXT NOT NULL,
+	dirty      BOOLEAN NOT NULL DEFAULT FALSE,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// Errors reported by Verify and the migration commands
+var (
+	ErrDirty          = errors.New("schema is dirty")
+	ErrPending        = errors.New("schema has pending migrations")
+	ErrDrift          = errors.New("applied migration differs from embedded version")
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+// Runner applies migrations to a database
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     zerolog.Logger
+}
+
+// Status describes one migration and its state in the database
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Dirty     bool
+	// Drifted is set when the applied checksum differs from the embedded one
+	Drifted bool
+	// Unknown is set for versions recorded in the database but missing
+	// from this binary
+	Unknown bool
+}
+
+type record struct {
+	version   uint
+	name      string
+	checksum  string
+	dirty     bool
+	appliedAt time.Time
+}
+
+// New creates a runner for the migrations in fsys
+func New(db *sql.DB, fsys fs.FS, logger zerolog.Logger) (*Runner, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{
+		db:         db,
+		migrations: migrations,
+		logger:     logger.With().Str("component", "migrate").Logger(),
+	}, nil
+}
+
+// Latest returns the highest embedded migration version
+func (r *Runner) Latest() uint {
+	if len(r.migrations) == 0 {
+		return 0
+	}
+	return r.migrations[len(r.migrations)-1].Version
+}
+
+// Up applies all pending migrations
+func (r *Runner) Up(ctx context.Context) error {
+	return r.Goto(ctx, r.Latest())
+}
+
+// Down reverts the most recently applied steps migrations
+func (r *Runner) Down(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("down: steps must be at least 1, got %d", steps)
+	}
+
+	return r.withLock(ctx, func(conn *sql.Conn) error {
+		records, err := r.records(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		var applied []uint
+		for _, m := range r.migrations {
+			if _, ok := records[m.Version]; ok {
+				applied = append(applied, m.Version)
+			}
+		}
+
+		target := uint(0)
+		if steps < len(applied) {
+			target = applied[len(applied)-1-steps]
+		}
+		return r.migrateTo(ctx, conn, records, target)
+	})
+}
+
+// Goto migrates up or down until exactly the migrations up to and
+// including version are applied. Version 0 reverts everything.
+func (r *Runner) Goto(ctx context.Context, version uint) error {
+	if version != 0 && !r.known(version) {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return r.withLock(ctx, func(conn *sql.Conn) error {
+		records, err := r.records(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return r.migrateTo(ctx, conn, records, version)
+	})
+}
+
+// Force discards the dirty row of version. The script of a dirty
+// migration never committed, so it is pending again afterwards; versions
+// that are applied cleanly are refused.
+func (r *Runner) Force(ctx context.Context, version uint) error {
+	return r.withLock(ctx, func(conn *sql.Conn) error {
+		res, err := conn.ExecContext(ctx,
+			`DELETE FROM schema_migrations WHERE version = $1 AND dirty`, version)
+		if err != nil {
+			return fmt.Errorf("force version %d: %w", version, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("force version %d: no dirty migration with this version", version)
+		}
+		r.logger.Warn().Uint("version", version).Msg("Discarded dirty migration")
+		return nil
+	})
+}
+
+// Status reports every embedded migration plus any unknown versions
+// recorded in the database
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		records, err := r.records(ctx, conn)
+		if err != nil {
+			return err
+		}
+		statuses = r.statuses(records)
+		return nil
+	})
+	return statuses, err
+}
+
+// Verify returns an error unless every embedded migration is applied,
+// clean and unchanged. It is run before the server accepts traffic.
+func (r *Runner) Verify(ctx context.Context) error {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return verify(statuses)
+}
+
+// Check is Verify without the migration lock, cheap enough for health
+// checks. A migration another replica is applying right now shows up as
+// pending.
+func (r *Runner) Check(ctx context.Context) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	records, err := r.records(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return verify(r.statuses(records))
+}
+
+func (r *Runner) statuses(records map[uint]record) []Status {
+	var statuses []Status
+	for _, m := range r.migrations {
+		s := Status{Migration: m}
+		if rec, ok := records[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = rec.appliedAt
+			s.Dirty = rec.dirty
+			s.Drifted = rec.checksum != m.Checksum
+			delete(records, m.Version)
+		}
+		statuses = append(statuses, s)
+	}
+
+	for _, rec := range records {
+		statuses = append(statuses, Status{
+			Migration: Migration{Version: rec.version, Name: rec.name, Checksum: rec.checksum},
+			Applied:   true,
+			AppliedAt: rec.appliedAt,
+			Dirty:     rec.dirty,
+			Unknown:   true,
+		})
+	}
+	return statuses
+}
+
+func verify(statuses []Status) error {
+	for _, s := range statuses {
+		switch {
+		case s.Dirty:
+			return fmt.Errorf("%w: migration %d_%s was interrupted", ErrDirty, s.Version, s.Name)
+		case s.Drifted:
+			return fmt.Errorf("%w: migration %d_%s was changed after it was applied", ErrDrift, s.Version, s.Name)
+		case !s.Applied:
+			return fmt.Errorf("%w: migration %d_%s has not been applied", ErrPending, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) known(version uint) bool {
+	for _, m := range r.migrations {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateTo must be called with the advisory lock held
+func (r *Runner) migrateTo(ctx context.Context, conn *sql.Conn, records map[uint]record, target uint) error {
+	applied := make(map[uint]bool, len(records))
+	for version, rec := range records {
+		if rec.dirty {
+			return fmt.Errorf("%w: migration %d_%s was interrupted; run force %d to discard it", ErrDirty, version, rec.name, version)
+		}
+		if !r.known(version) {
+			return fmt.Errorf("%w: database has version %d which this binary does not contain", ErrUnknownVersion, version)
+		}
+		applied[version] = true
+	}
+
+	for _, m := range r.migrations {
+		if rec, ok := records[m.Version]; ok && rec.checksum != m.Checksum {
+			return fmt.Errorf("%w: migration %d_%s was changed after it was applied", ErrDrift, m.Version, m.Name)
+		}
+	}
+
+	up, down := plan(r.migrations, applied, target)
+	if len(up) == 0 && len(down) == 0 {
+		r.logger.Info().Uint("version", target).Msg("Schema is up to date")
+		return nil
+	}
+
+	for _, m := range down {
+		if err := r.apply(ctx, conn, m, false); err != nil {
+			return err
+		}
+	}
+	for _, m := range up {
+		if err := r.apply(ctx, conn, m, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply runs one migration script and records it in the same
+// transaction, so the row exists exactly when the script's changes do
+func (r *Runner) apply(ctx context.Context, conn *sql.Conn, m Migration, up bool) error {
+	start := time.Now()
+	direction := "down"
+	script := m.Down
+	record := `DELETE FROM schema_migrations WHERE version = $1`
+	args := []interface{}{m.Version}
+	if up {
+		direction = "up"
+		script = m.Up
+		record = `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`
+		args = []interface{}{m.Version, m.Name, m.Checksum}
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %w", m.Version, m.Name, direction, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("record migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit migration %d: %w", m.Version, err)
+	}
+
+	r.logger.Info().
+		Uint("version", m.Version).
+		Str("name", m.Name).
+		Str("direction", direction).
+		Dur("duration", time.Since(start)).
+		Msg("Applied migration")
+	return nil
+}
+
+// withLock runs fn on a dedicated connection holding the session-level
+// advisory lock and makes sure schema_migrations exists
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (r *Runner) records(ctx context.Context, conn *sql.Conn) (map[uint]record, error) {
+	rows, err := conn.QueryContext(ctx,
+		`SELECT version, name, checksum, dirty, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	records := make(map[uint]record)
+	for rows.Next() {
+		var rec record
+		var version int64
+		if err := rows.Scan(&version, &rec.name, &rec.checksum, &rec.dirty, &rec.appliedAt); err != nil {
+			return nil, err
+		}
+		rec.version = uint(version)
+		records[rec.version] = rec
+	}
+	return records, rows.Err()
+}
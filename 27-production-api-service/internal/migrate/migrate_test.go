@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// server is a database/sql connector with an empty schema_migrations
+// table. It records the statements it receives and fails the script
+// "FAIL".
+type server struct{ log []string }
+
+func (s *server) Connect(context.Context) (driver.Conn, error) { return serverConn{s}, nil }
+func (s *server) Driver() driver.Driver                        { return nil }
+
+type serverConn struct{ s *server }
+
+func (c serverConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c serverConn) Close() error                        { return nil }
+
+func (c serverConn) Begin() (driver.Tx, error) {
+	c.s.log = append(c.s.log, "BEGIN")
+	return c, nil
+}
+
+func (c serverConn) Commit() error {
+	c.s.log = append(c.s.log, "COMMIT")
+	return nil
+}
+
+func (c serverConn) Rollback() error {
+	c.s.log = append(c.s.log, "ROLLBACK")
+	return nil
+}
+
+func (c serverConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query = strings.TrimSpace(query)
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory"), strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		return driver.RowsAffected(0), nil
+	case strings.Contains(query, "schema_migrations"):
+		query = strings.Join(strings.Fields(query)[:3], " ")
+	}
+	c.s.log = append(c.s.log, query)
+	if query == "FAIL" {
+		return nil, errors.New("syntax error")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c serverConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string {
+	return []string{"version", "name", "checksum", "dirty", "applied_at"}
+}
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
+
+func TestFailedMigrationLeavesNoRecord(t *testing.T) {
+	s := &server{}
+	r := &Runner{
+		db: sql.OpenDB(s),
+		migrations: []Migration{
+			{Version: 1, Name: "init", Up: "CREATE a"},
+			{Version: 2, Name: "broken", Up: "FAIL"},
+		},
+		logger: zerolog.Nop(),
+	}
+
+	if err := r.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "syntax error") {
+		t.Fatalf("Up = %v; want the script error", err)
+	}
+	want := []string{
+		"BEGIN", "CREATE a", "INSERT INTO schema_migrations", "COMMIT",
+		"BEGIN", "FAIL", "ROLLBACK",
+	}
+	if !reflect.DeepEqual(s.log, want) {
+		t.Errorf("statements = %q; want %q", s.log, want)
+	}
+}
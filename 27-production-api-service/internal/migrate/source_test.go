@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"example.com/production-api/migrations"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	ms, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(ms) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, m := range ms {
+		if m.Version != uint(i+1) {
+			t.Errorf("migration %d has version %d; versions must be contiguous from 1", i, m.Version)
+		}
+		if len(m.Checksum) != 64 {
+			t.Errorf("migration %d: checksum %q is not a SHA-256 hex digest", m.Version, m.Checksum)
+		}
+	}
+}
+
+func TestLoadRejectsIncompletePairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr string
+	}{
+		{
+			name:    "missing down",
+			files:   fstest.MapFS{"000001_init.up.sql": {Data: []byte("SELECT 1")}},
+			wantErr: "missing down",
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"000001_init.up.sql":  {Data: []byte("SELECT 1")},
+				"000001_other.up.sql": {Data: []byte("SELECT 1")},
+			},
+			wantErr: "conflicting names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.files)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v; want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	ms := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+
+	tests := []struct {
+		name     string
+		applied  map[uint]bool
+		target   uint
+		wantUp   []uint
+		wantDown []uint
+	}{
+		{"fresh database to latest", nil, 3, []uint{1, 2, 3}, nil},
+		{"partially applied", map[uint]bool{1: true}, 3, []uint{2, 3}, nil},
+		{"roll back two", map[uint]bool{1: true, 2: true, 3: true}, 1, nil, []uint{3, 2}},
+		{"revert everything", map[uint]bool{1: true, 2: true}, 0, nil, []uint{2, 1}},
+		{"fill gap below target", map[uint]bool{1: true, 3: true}, 3, []uint{2}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, down := plan(ms, tt.applied, tt.target)
+			if got := versions(up); !equal(got, tt.wantUp) {
+				t.Errorf("up = %v; want %v", got, tt.wantUp)
+			}
+			if got := versions(down); !equal(got, tt.wantDown) {
+				t.Errorf("down = %v; want %v", got, tt.wantDown)
+			}
+		})
+	}
+}
+
+func versions(ms []Migration) []uint {
+	var vs []uint
+	for _, m := range ms {
+		vs = append(vs, m.Version)
+	}
+	return vs
+}
+
+func equal(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
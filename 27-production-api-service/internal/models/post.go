@@ -3,6 +3,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Post represents a blog post
@@ -15,7 +17,13 @@ type Post struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
-	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	// Version is incremented on every write and backs the ETag
+	Version uint `gorm:"not null;default:1" json:"version"`
+	// DeletedAt is set while the post is soft-deleted
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+
+	// User is the author, only loaded when explicitly preloaded
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
 // TableName specifies the table name
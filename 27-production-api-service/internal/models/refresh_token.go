@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is a single-use refresh token. Only the SHA-256 of the
+// token is stored. Tokens issued from one login share a family, so
+// replaying a rotated token can revoke the whole session.
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null"`
+	Family    string    `gorm:"size:64;not null"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TableName specifies the table name
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
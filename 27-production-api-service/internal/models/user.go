@@ -3,16 +3,33 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// Roles a user can have
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
 )
 
 // User represents a user in the system
 type User struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Name      string    `gorm:"size:100;not null" json:"name" validate:"required,min=2"`
-	Email     string    `gorm:"size:100;uniqueIndex;not null" json:"email" validate:"required,email"`
+	Email     string    `gorm:"size:100;uniqueIndex:users_email_active_key,where:deleted_at IS NULL;not null" json:"email" validate:"required,email"`
+	Role      string    `gorm:"size:20;not null;default:user" json:"role" validate:"required,oneof=user admin"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
+	// Version is incremented on every write and backs the ETag
+	Version uint `gorm:"not null;default:1" json:"version"`
+	// DeletedAt is set while the user is soft-deleted
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+
+	// PasswordHash is the bcrypt hash; users without one cannot log in
+	PasswordHash string `gorm:"size:255" json:"-"`
+
 	Posts []Post `gorm:"foreignKey:UserID" json:"posts,omitempty"`
 }
 
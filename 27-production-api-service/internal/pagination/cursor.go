@@ -0,0 +1,71 @@
+package pagination
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// cursor is the opaque keyset position handed to clients. It records the
+// sort it was issued for so it cannot be replayed against another order.
+type cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+func (c *cursor) encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var c cursor
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	// Keep integers exact instead of letting them become float64
+	for i, v := range c.Values {
+		if n, ok := v.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				c.Values[i] = iv
+			} else if fv, err := n.Float64(); err == nil {
+				c.Values[i] = fv
+			}
+		}
+	}
+	return &c, nil
+}
+
+// nextCursor reads the sort column values from the last row on the page
+// using the schema GORM parsed for the row type.
+func (req *Request) nextCursor(ctx context.Context, s *schema.Schema, last reflect.Value) (string, error) {
+	row := reflect.Indirect(last)
+	c := &cursor{Sort: req.sort}
+	for _, o := range req.orders {
+		field := s.LookUpField(o.column)
+		if field == nil {
+			return "", errors.New("pagination: unknown sort column " + o.column)
+		}
+		v, _ := field.ValueOf(ctx, row)
+		c.Values = append(c.Values, v)
+	}
+
+	return c.encode()
+}
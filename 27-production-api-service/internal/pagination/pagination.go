@@ -0,0 +1,411 @@
+// Package pagination provides offset and keyset pagination, whitelisted
+// sorting and filtering for GORM list queries.
+//
+// A handler declares a Spec once, parses each request with Parse and
+// runs the query with Find:
+//
+//	req, err := pagination.Parse(r, userListSpec)
+//	...
+//	page, err := pagination.Find(db.Model(&models.User{}), req, &users)
+//	...
+//	page.WriteHeaders(w)
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidParams is wrapped by every error returned from Parse
+var ErrInvalidParams = errors.New("invalid list parameters")
+
+// Op is the comparison a filter applies to its column
+type Op int
+
+const (
+	// Equal matches the column exactly
+	Equal Op = iota
+	// Prefix matches columns starting with the value
+	Prefix
+	// After matches timestamps at or after the value (RFC 3339)
+	After
+	// Before matches timestamps strictly before the value (RFC 3339)
+	Before
+)
+
+// Kind is the type of value an Equal filter accepts. Values of another
+// type are rejected by Parse instead of failing in the database.
+type Kind int
+
+const (
+	// Text accepts any value
+	Text Kind = iota
+	// Integer accepts non-negative integers such as IDs
+	Integer
+	// Boolean accepts true or false
+	Boolean
+)
+
+// Filter maps a query parameter onto a column
+type Filter struct {
+	Param  string
+	Column string
+	Op     Op
+	Kind   Kind
+}
+
+// Spec describes what a list endpoint allows clients to do
+type Spec struct {
+	// Sorts maps sort names accepted in ?sort= to column names
+	Sorts map[string]string
+	// DefaultSort is used when ?sort= is absent, e.g. "-created_at"
+	DefaultSort string
+	// Filters lists the accepted filter parameters
+	Filters []Filter
+	// DefaultLimit and MaxLimit bound ?limit=
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Request is a parsed and validated list request
+type Request struct {
+	Limit  int
+	Offset int
+
+	sort       string
+	orders     []order
+	conditions []condition
+	cursor     *cursor
+	url        url.URL
+}
+
+type order struct {
+	column string
+	desc   bool
+}
+
+type condition struct {
+	column string
+	op     Op
+	// value is a string, a uint64 or bool for Integer and Boolean
+	// filters, or a time.Time for After and Before
+	value interface{}
+}
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// Parse reads limit, offset, cursor, sort and filter parameters from r.
+// Unknown sort fields and malformed values are rejected.
+func Parse(r *http.Request, spec Spec) (*Request, error) {
+	req, err := ParseValues(r.URL.Query(), spec)
+	if err != nil {
+		return nil, err
+	}
+	req.url = *r.URL
+	return req, nil
+}
+
+// ParseValues is Parse for callers without an HTTP request, such as the
+// CLI. Pages parsed this way produce no Link headers.
+func ParseValues(q url.Values, spec Spec) (*Request, error) {
+	req := &Request{}
+
+	if spec.DefaultLimit <= 0 {
+		spec.DefaultLimit = defaultLimit
+	}
+	if spec.MaxLimit <= 0 {
+		spec.MaxLimit = maxLimit
+	}
+
+	req.Limit = spec.DefaultLimit
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > spec.MaxLimit {
+			return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidParams, spec.MaxLimit)
+		}
+		req.Limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("%w: offset must be a non-negative integer", ErrInvalidParams)
+		}
+		req.Offset = offset
+	}
+
+	req.sort = q.Get("sort")
+	if req.sort == "" {
+		req.sort = spec.DefaultSort
+	}
+	orders, err := parseSort(req.sort, spec.Sorts)
+	if err != nil {
+		return nil, err
+	}
+	req.orders = orders
+
+	if v := q.Get("cursor"); v != "" {
+		if req.Offset > 0 {
+			return nil, fmt.Errorf("%w: cursor and offset cannot be combined", ErrInvalidParams)
+		}
+		c, err := decodeCursor(v)
+		if err != nil || c.Sort != req.sort || len(c.Values) != len(req.orders) {
+			return nil, fmt.Errorf("%w: cursor is malformed or does not match sort", ErrInvalidParams)
+		}
+		req.cursor = c
+	}
+
+	for _, f := range spec.Filters {
+		v := q.Get(f.Param)
+		if v == "" {
+			continue
+		}
+		cond, err := f.condition(v)
+		if err != nil {
+			return nil, err
+		}
+		req.conditions = append(req.conditions, cond)
+	}
+
+	return req, nil
+}
+
+// parseSort turns "name,-created_at" into orders and always appends the
+// primary key as a tie breaker so keyset pagination is stable.
+func parseSort(sort string, allowed map[string]string) ([]order, error) {
+	var orders []order
+	hasID := false
+
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(part, "-")
+
+		column, ok := allowed[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidParams, name)
+		}
+		if column == "id" {
+			hasID = true
+		}
+		orders = append(orders, order{column: column, desc: desc})
+	}
+
+	if !hasID {
+		orders = append(orders, order{column: "id"})
+	}
+	return orders, nil
+}
+
+func (f Filter) condition(v string) (condition, error) {
+	c := condition{column: f.Column, op: f.Op, value: v}
+	switch {
+	case f.Op == After || f.Op == Before:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return condition{}, fmt.Errorf("%w: %s must be an RFC 3339 timestamp", ErrInvalidParams, f.Param)
+		}
+		c.value = t
+	case f.Kind == Integer:
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return condition{}, fmt.Errorf("%w: %s must be a non-negative integer", ErrInvalidParams, f.Param)
+		}
+		c.value = n
+	case f.Kind == Boolean:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return condition{}, fmt.Errorf("%w: %s must be true or false", ErrInvalidParams, f.Param)
+		}
+		c.value = b
+	}
+	return c, nil
+}
+
+func (c condition) sql() (string, interface{}) {
+	switch c.op {
+	case Prefix:
+		return c.column + " LIKE ?", escapeLike(c.value.(string)) + "%"
+	case After:
+		return c.column + " >= ?", c.value
+	case Before:
+		return c.column + " < ?", c.value
+	default:
+		return c.column + " = ?", c.value
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Filter applies the request's filter conditions to query
+func (req *Request) Filter(query *gorm.DB) *gorm.DB {
+	for _, c := range req.conditions {
+		sql, value := c.sql()
+		query = query.Where(sql, value)
+	}
+	return query
+}
+
+// Page describes the slice of results that was returned
+type Page struct {
+	Total      int64
+	Limit      int
+	Offset     int
+	NextCursor string
+
+	req *Request
+	// n is the number of rows on this page
+	n int
+}
+
+// Find counts all rows matching the filters, then loads one page into
+// dest. Scopes are applied to the data query only, which keeps clauses
+// like Preload out of the count.
+func Find[T any](query *gorm.DB, req *Request, dest *[]T, scopes ...func(*gorm.DB) *gorm.DB) (*Page, error) {
+	query = req.Filter(query)
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	data := query.Scopes(scopes...)
+	for _, o := range req.orders {
+		data = data.Order(clause.OrderByColumn{Column: clause.Column{Name: o.column}, Desc: o.desc})
+	}
+	if req.cursor != nil {
+		sql, vars := req.keyset()
+		data = data.Where(sql, vars...)
+	} else if req.Offset > 0 {
+		data = data.Offset(req.Offset)
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	result := data.Limit(req.Limit + 1).Find(dest)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	page := &Page{Total: total, Limit: req.Limit, Offset: req.Offset, req: req}
+
+	hasMore := len(*dest) > req.Limit
+	if hasMore {
+		*dest = (*dest)[:req.Limit]
+	}
+	page.n = len(*dest)
+
+	if hasMore && len(*dest) > 0 {
+		if result.Statement.Schema == nil {
+			return nil, errors.New("pagination: query has no parsed schema")
+		}
+		last := reflect.ValueOf((*dest)[len(*dest)-1])
+		next, err := req.nextCursor(result.Statement.Context, result.Statement.Schema, last)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+
+	return page, nil
+}
+
+// keyset builds the row comparison that selects rows after the cursor:
+// ((a > ?) OR (a = ? AND b > ?) OR ...)
+func (req *Request) keyset() (string, []interface{}) {
+	var (
+		ors  []string
+		vars []interface{}
+	)
+
+	for i, o := range req.orders {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, req.orders[j].column+" = ?")
+			vars = append(vars, req.cursor.Values[j])
+		}
+		op := " > ?"
+		if o.desc {
+			op = " < ?"
+		}
+		ands = append(ands, o.column+op)
+		vars = append(vars, req.cursor.Values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", vars
+}
+
+// WriteHeaders sets X-Total-Count and an RFC 8288 Link header with
+// first, prev, next and last relations where they apply. X-Next-Cursor
+// is set whenever more rows exist, so clients can switch from offset to
+// keyset pagination after the first page.
+func (p *Page) WriteHeaders(w http.ResponseWriter) {
+	w.Header().Set("X-Total-Count", strconv.FormatInt(p.Total, 10))
+	if p.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", p.NextCursor)
+	}
+
+	var links []string
+	add := func(rel string, set map[string]string) {
+		u := p.req.url
+		q := u.Query()
+		q.Del("offset")
+		q.Del("cursor")
+		for k, v := range set {
+			if v != "" {
+				q.Set(k, v)
+			}
+		}
+		u.RawQuery = q.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel))
+	}
+
+	add("first", nil)
+
+	if p.req.cursor != nil {
+		if p.NextCursor != "" {
+			add("next", map[string]string{"cursor": p.NextCursor})
+		}
+	} else {
+		if p.Offset > 0 {
+			prev := p.Offset - p.Limit
+			if prev < 0 {
+				prev = 0
+			}
+			add("prev", map[string]string{"offset": offsetParam(prev)})
+		}
+		if int64(p.Offset+p.n) < p.Total {
+			add("next", map[string]string{"offset": offsetParam(p.Offset + p.Limit)})
+		}
+		if p.Total > 0 {
+			last := int((p.Total - 1) / int64(p.Limit) * int64(p.Limit))
+			add("last", map[string]string{"offset": offsetParam(last)})
+		}
+	}
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+func offsetParam(offset int) string {
+	if offset == 0 {
+		return ""
+	}
+	return strconv.Itoa(offset)
+}
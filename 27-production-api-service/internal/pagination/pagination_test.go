@@ -0,0 +1,207 @@
+package pagination
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID        uint
+	Name      string
+	CreatedAt time.Time
+}
+
+var testSpec = Spec{
+	Sorts:       map[string]string{"id": "id", "name": "name", "created_at": "created_at"},
+	DefaultSort: "id",
+	Filters: []Filter{
+		{Param: "name", Column: "name", Op: Prefix},
+		{Param: "created_after", Column: "created_at", Op: After},
+		{Param: "owner_id", Column: "owner_id", Op: Equal, Kind: Integer},
+		{Param: "active", Column: "active", Op: Equal, Kind: Boolean},
+	},
+	MaxLimit: 50,
+}
+
+// dryRunDB builds SQL without connecting to Postgres
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open dry-run db: %v", err)
+	}
+	return db
+}
+
+func TestParseRejectsInvalidParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"limit too large", "limit=51"},
+		{"limit not a number", "limit=abc"},
+		{"negative offset", "offset=-1"},
+		{"unknown sort field", "sort=password"},
+		{"bad timestamp", "created_after=yesterday"},
+		{"non-numeric ID", "owner_id=abc"},
+		{"negative ID", "owner_id=-1"},
+		{"bad boolean", "active=maybe"},
+		{"garbage cursor", "cursor=not-a-cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/items?"+tt.query, nil)
+			_, err := Parse(r, testSpec)
+			if !errors.Is(err, ErrInvalidParams) {
+				t.Errorf("Parse(%q) error = %v; want ErrInvalidParams", tt.query, err)
+			}
+		})
+	}
+}
+
+func TestParseAppendsIDTieBreaker(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items?sort=-name", nil)
+	req, err := Parse(r, testSpec)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := []order{{column: "name", desc: true}, {column: "id"}}
+	if len(req.orders) != len(want) {
+		t.Fatalf("orders = %v; want %v", req.orders, want)
+	}
+	for i := range want {
+		if req.orders[i] != want[i] {
+			t.Errorf("orders[%d] = %v; want %v", i, req.orders[i], want[i])
+		}
+	}
+}
+
+func TestCursorMustMatchSort(t *testing.T) {
+	c, err := (&cursor{Sort: "-name", Values: []interface{}{"bob", 7}}).encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "/items?sort=-name&cursor="+c, nil)
+	req, err := Parse(r, testSpec)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := req.cursor.Values[1]; got != int64(7) {
+		t.Errorf("cursor id = %#v; want int64(7)", got)
+	}
+
+	r = httptest.NewRequest("GET", "/items?sort=name&cursor="+c, nil)
+	if _, err := Parse(r, testSpec); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("cursor reused with another sort: error = %v; want ErrInvalidParams", err)
+	}
+}
+
+func TestKeysetSQL(t *testing.T) {
+	c, _ := (&cursor{Sort: "-name", Values: []interface{}{"bob", 7}}).encode()
+	r := httptest.NewRequest("GET", "/items?sort=-name&name=b_&cursor="+c, nil)
+	req, err := Parse(r, testSpec)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	db := dryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		query := req.Filter(tx.Model(&item{}))
+		where, vars := req.keyset()
+		var items []item
+		return query.Where(where, vars...).Find(&items)
+	})
+
+	for _, want := range []string{
+		`name LIKE 'b\_%'`,
+		`((name < 'bob') OR (name = 'bob' AND id > 7))`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL %q does not contain %q", sql, want)
+		}
+	}
+}
+
+func TestWriteHeadersOffsetLinks(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items?limit=10&offset=10&name=a", nil)
+	req, err := Parse(r, testSpec)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	page := &Page{Total: 35, Limit: 10, Offset: 10, req: req, n: 10}
+	w := httptest.NewRecorder()
+	page.WriteHeaders(w)
+
+	if got := w.Header().Get("X-Total-Count"); got != "35" {
+		t.Errorf("X-Total-Count = %q; want 35", got)
+	}
+
+	link := w.Header().Get("Link")
+	for _, want := range []string{
+		`</items?limit=10&name=a>; rel="first"`,
+		`</items?limit=10&name=a>; rel="prev"`,
+		`</items?limit=10&name=a&offset=20>; rel="next"`,
+		`</items?limit=10&name=a&offset=30>; rel="last"`,
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link %q does not contain %q", link, want)
+		}
+	}
+}
+
+func TestSliceWalksAllPagesWithCursor(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := []item{
+		{ID: 1, Name: "carol", CreatedAt: base},
+		{ID: 2, Name: "alice", CreatedAt: base.Add(time.Hour)},
+		{ID: 3, Name: "bob", CreatedAt: base.Add(time.Hour)},
+		{ID: 4, Name: "dave", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: 5, Name: "erin", CreatedAt: base.Add(3 * time.Hour)},
+	}
+
+	var got []uint
+	query := "sort=-created_at&limit=2"
+	for pages := 0; pages < 5; pages++ {
+		req, err := Parse(httptest.NewRequest("GET", "/items?"+query, nil), testSpec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", query, err)
+		}
+		result, page, err := Slice(items, req)
+		if err != nil {
+			t.Fatalf("Slice: %v", err)
+		}
+		if page.Total != 5 {
+			t.Errorf("Total = %d; want 5", page.Total)
+		}
+		for _, it := range result {
+			got = append(got, it.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query = "sort=-created_at&limit=2&cursor=" + page.NextCursor
+	}
+
+	want := []uint{5, 4, 2, 3, 1}
+	if len(got) != len(want) {
+		t.Fatalf("walked %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("walked %v; want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// schemaCache holds parsed schemas for Slice
+var schemaCache sync.Map
+
+// Slice pages through an in-memory slice with the same filtering, sorting
+// and cursor semantics as Find. It backs the in-memory repositories.
+func Slice[T any](items []T, req *Request) ([]T, *Page, error) {
+	s, err := schema.Parse(new(T), &schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx := context.Background()
+
+	value := func(item T, column string) interface{} {
+		field := s.LookUpField(column)
+		if field == nil {
+			return nil
+		}
+		v, _ := field.ValueOf(ctx, reflect.ValueOf(item))
+		return v
+	}
+
+	var matched []T
+	for _, item := range items {
+		if req.matches(func(column string) interface{} { return value(item, column) }) {
+			matched = append(matched, item)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		for _, o := range req.orders {
+			c := compare(value(matched[i], o.column), value(matched[j], o.column))
+			if c != 0 {
+				return (c < 0) != o.desc
+			}
+		}
+		return false
+	})
+
+	page := &Page{Total: int64(len(matched)), Limit: req.Limit, Offset: req.Offset, req: req}
+
+	start := req.Offset
+	if req.cursor != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return req.afterCursor(func(column string) interface{} { return value(matched[i], column) })
+		})
+	}
+	if start > len(matched) {
+		start = len(matched)
+	}
+
+	end := start + req.Limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	result := matched[start:end]
+	page.n = len(result)
+
+	if end < len(matched) && len(result) > 0 {
+		next, err := req.nextCursor(ctx, s, reflect.ValueOf(result[len(result)-1]))
+		if err != nil {
+			return nil, nil, err
+		}
+		page.NextCursor = next
+	}
+
+	return result, page, nil
+}
+
+// matches reports whether a row satisfies every filter condition
+func (req *Request) matches(get func(column string) interface{}) bool {
+	for _, c := range req.conditions {
+		v := get(c.column)
+		switch c.op {
+		case Prefix:
+			if !strings.HasPrefix(fmt.Sprint(v), c.value.(string)) {
+				return false
+			}
+		case After:
+			if compare(v, c.value) < 0 {
+				return false
+			}
+		case Before:
+			if compare(v, c.value) >= 0 {
+				return false
+			}
+		default:
+			if fmt.Sprint(v) != fmt.Sprint(c.value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// afterCursor reports whether a row sorts strictly after the cursor
+func (req *Request) afterCursor(get func(column string) interface{}) bool {
+	for i, o := range req.orders {
+		c := compare(get(o.column), req.cursor.Values[i])
+		if o.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0
+		}
+	}
+	return false
+}
+
+// compare orders two column values. Cursor values arrive as JSON types,
+// so timestamps may be strings and integers int64.
+func compare(a, b interface{}) int {
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		if !ok {
+			bt, _ = time.Parse(time.RFC3339Nano, fmt.Sprint(b))
+		}
+		return at.Compare(bt)
+	}
+
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if ab, ok := a.(bool); ok {
+		bb, _ := b.(bool)
+		switch {
+		case ab == bb:
+			return 0
+		case !ab:
+			return -1
+		}
+		return 1
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,103 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON values
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Media types of the supported patch formats
+const (
+	MergePatch = "application/merge-patch+json"
+	JSONPatch  = "application/json-patch+json"
+)
+
+// Accept lists the supported formats for the Accept-Patch header
+const Accept = MergePatch + ", " + JSONPatch
+
+// Errors returned by Apply. Each is wrapped with details about the
+// failing operation.
+var (
+	// ErrUnsupported reports a media type that is not a patch format
+	ErrUnsupported = errors.New("patch: unsupported media type")
+	// ErrInvalid reports a malformed patch document
+	ErrInvalid = errors.New("patch: invalid patch document")
+	// ErrUnprocessable reports a well-formed patch that cannot be
+	// applied to the document, such as one naming a missing path
+	ErrUnprocessable = errors.New("patch: cannot apply patch")
+	// ErrTestFailed reports a JSON Patch test operation that did not match
+	ErrTestFailed = errors.New("patch: test operation failed")
+)
+
+// Apply applies patch in the format given by mediaType to doc and
+// returns the patched document
+func Apply(mediaType string, doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case MergePatch:
+		p, err := decode(patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		target = merge(target, p)
+	case JSONPatch:
+		var ops []operation
+		if err := json.Unmarshal(patch, &ops); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		for i, op := range ops {
+			if target, err = op.apply(target); err != nil {
+				return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupported, mediaType)
+	}
+
+	return json.Marshal(target)
+}
+
+// decode parses a JSON value, keeping numbers exact
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// merge implements the MergePatch algorithm of RFC 7396: objects are
+// merged member by member, null removes a member and anything else
+// replaces the target
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = merge(t[name], value)
+		}
+	}
+	return t
+}
@@ -0,0 +1,127 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// sameJSON compares two JSON documents regardless of member order
+func sameJSON(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result is not JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("want is not JSON: %v", err)
+	}
+	gb, _ := json.Marshal(g)
+	wb, _ := json.Marshal(w)
+	return string(gb) == string(wb)
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"null removes", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"arrays are replaced", `{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{"nested merge", `{"a":{"b":"c","d":"e"}}`, `{"a":{"d":null,"f":1}}`, `{"a":{"b":"c","f":1}}`},
+		{"scalar becomes object", `{"a":"b"}`, `{"a":{"c":null}}`, `{"a":{}}`},
+		{"non-object replaces document", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"false and zero are values", `{"a":true,"n":3}`, `{"a":false,"n":0}`, `{"a":false,"n":0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(MergePatch, []byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"insert into array", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"append to array", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"baz"}]`, `{"foo":["bar","baz"]}`},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"replace with null", `{"a":"b"}`, `[{"op":"replace","path":"/a","value":null}]`, `{"a":null}`},
+		{"move", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"copy is deep", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`},
+		{"test then replace", `{"n":1}`, `[{"op":"test","path":"/n","value":1.0},{"op":"replace","path":"/n","value":2}]`, `{"n":2}`},
+		{"escaped pointer", `{"a/b":1,"m~n":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/m~0n"}]`, `{}`},
+		{"replace document", `{"a":1}`, `[{"op":"replace","path":"","value":{"b":2}}]`, `{"b":2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(JSONPatch, []byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	tests := []struct {
+		name, patch string
+		want        error
+	}{
+		{"not an array", `{"op":"add"}`, ErrInvalid},
+		{"unknown op", `[{"op":"frobnicate","path":"/a"}]`, ErrInvalid},
+		{"missing value", `[{"op":"add","path":"/b"}]`, ErrInvalid},
+		{"relative pointer", `[{"op":"remove","path":"a"}]`, ErrInvalid},
+		{"missing member", `[{"op":"remove","path":"/nope"}]`, ErrUnprocessable},
+		{"missing parent", `[{"op":"add","path":"/x/y","value":1}]`, ErrUnprocessable},
+		{"index out of range", `[{"op":"add","path":"/list/5","value":1}]`, ErrUnprocessable},
+		{"leading zero index", `[{"op":"remove","path":"/list/01"}]`, ErrUnprocessable},
+		{"move into own child", `[{"op":"move","from":"/obj","path":"/obj/inner"}]`, ErrUnprocessable},
+		{"failed test", `[{"op":"test","path":"/a","value":"other"}]`, ErrTestFailed},
+	}
+
+	doc := []byte(`{"a":"b","list":[1,2],"obj":{}}`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply(JSONPatch, doc, []byte(tt.patch)); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPatchIsAtomic(t *testing.T) {
+	doc := []byte(`{"a":1}`)
+	_, err := Apply(JSONPatch, doc, []byte(`[{"op":"replace","path":"/a","value":2},{"op":"test","path":"/a","value":1}]`))
+	if !errors.Is(err, ErrTestFailed) {
+		t.Fatalf("err = %v; want ErrTestFailed", err)
+	}
+	if string(doc) != `{"a":1}` {
+		t.Errorf("input document changed to %s", doc)
+	}
+}
+
+func TestUnsupportedMediaType(t *testing.T) {
+	if _, err := Apply("application/json", []byte(`{}`), []byte(`{}`)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("err = %v; want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package policy decides what an authenticated caller may do. Routes
+// declare their requirements in server.NewRouter:
+//
+//	r.With(pol.Require(policy.Admin, policy.Self("id"))).Put("/{id}", ...)
+//
+// A request passes when any listed rule allows it. API keys are further
+// limited by RequireScope. Denials are answered with 403 and logged with
+// enough context to audit them.
+package policy
+
+import (
+	"errors"
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/logging"
+	"example.com/production-api/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/fx"
+)
+
+// Module provides the policy enforcer
+var Module = fx.Options(
+	fx.Provide(NewEnforcer),
+)
+
+// Rule grants access when Allow returns true. Name identifies the rule
+// in denial logs.
+type Rule struct {
+	Name  string
+	Allow func(r *http.Request, p *auth.Principal) (bool, error)
+}
+
+// Admin allows callers with the admin role
+var Admin = Rule{
+	Name: "admin",
+	Allow: func(r *http.Request, p *auth.Principal) (bool, error) {
+		return p.IsAdmin(), nil
+	},
+}
+
+// Self allows callers whose user ID is the URL parameter param
+func Self(param string) Rule {
+	return Rule{
+		Name: "self",
+		Allow: func(r *http.Request, p *auth.Principal) (bool, error) {
+			id, err := strconv.ParseUint(chi.URLParam(r, param), 10, 64)
+			return err == nil && uint(id) == p.UserID, nil
+		},
+	}
+}
+
+// Owns reports whether p may act on a resource owned by userID
+func Owns(p *auth.Principal, userID uint) bool {
+	return p.IsAdmin() || (p != nil && p.UserID == userID)
+}
+
+// PostVisibility restricts post reads for p: anonymous callers see only
+// published posts, users also see their own drafts, admins see all
+func PostVisibility(p *auth.Principal, q repository.PostQuery) repository.PostQuery {
+	if p.IsAdmin() {
+		return q
+	}
+	q.PublishedOnly = true
+	if p != nil {
+		q.DraftsBy = p.UserID
+	}
+	return q
+}
+
+// Enforcer checks route requirements. Ownership rules load the resource
+// through the repositories.
+type Enforcer struct {
+	posts repository.PostRepository
+}
+
+// NewEnforcer creates an enforcer
+func NewEnforcer(posts repository.PostRepository) *Enforcer {
+	return &Enforcer{posts: posts}
+}
+
+// PostOwner allows the author of the post in URL parameter param.
+// Missing posts are let through so the handler can answer 404.
+func (e *Enforcer) PostOwner(param string) Rule {
+	return Rule{
+		Name: "post_owner",
+		Allow: func(r *http.Request, p *auth.Principal) (bool, error) {
+			id, err := strconv.ParseUint(chi.URLParam(r, param), 10, 64)
+			if err != nil {
+				return false, nil
+			}
+			post, err := e.posts.Get(r.Context(), uint(id), repository.PostQuery{})
+			if errors.Is(err, repository.ErrNotFound) {
+				return true, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			return post.UserID == p.UserID, nil
+		},
+	}
+}
+
+// Require returns middleware that lets a request through if any rule
+// allows it. Anonymous callers get 401 before any rule is evaluated.
+func (e *Enforcer) Require(rules ...Rule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return auth.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := auth.PrincipalFrom(r.Context())
+			for _, rule := range rules {
+				ok, err := rule.Allow(r, p)
+				if err != nil {
+					apierror.Write(w, r, apierror.Internal(err))
+					return
+				}
+				if ok {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			Deny(w, r, names(rules))
+		}))
+	}
+}
+
+// RequireScope returns middleware that stops API keys lacking scope.
+// Anonymous callers and access tokens are not affected; combine it with
+// Require to demand a caller.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.PrincipalFrom(r.Context()).HasScope(scope) {
+				Deny(w, r, "scope:"+scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Deny logs the denied request and answers 403. required describes what
+// the caller lacked.
+func Deny(w http.ResponseWriter, r *http.Request, required string) {
+	p := auth.PrincipalFrom(r.Context())
+	event := logging.FromContext(r.Context()).Warn().
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Str("required", required)
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		event = event.Str("route", rctx.RoutePattern())
+	}
+	if p != nil {
+		event = event.Uint("user_id", p.UserID).Str("role", p.Role)
+		if p.APIKeyID != 0 {
+			event = event.Uint("api_key_id", p.APIKeyID)
+		}
+	}
+	event.Msg("Access denied")
+
+	apierror.Write(w, r, apierror.Forbidden("you are not allowed to perform this action"))
+}
+
+func names(rules []Rule) string {
+	n := make([]string, len(rules))
+	for i, rule := range rules {
+		n[i] = rule.Name
+	}
+	return strings.Join(n, "|")
+}
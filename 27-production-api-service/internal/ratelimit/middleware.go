@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/logging"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limiter applies the configured rate limits to route groups
+type Limiter struct {
+	store   Store
+	enabled bool
+
+	// mu guards the rules, which change on config reload
+	mu     sync.RWMutex
+	def    config.RateLimitRule
+	groups map[string]config.RateLimitRule
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewLimiter creates a limiter from the rate limit configuration
+func NewLimiter(cfg *config.Config, store Store) *Limiter {
+	return &Limiter{
+		store:   store,
+		enabled: cfg.RateLimit.Enabled,
+		def:     cfg.RateLimit.Default,
+		groups:  cfg.RateLimit.Groups,
+		now:     time.Now,
+	}
+}
+
+// Apply switches to the rules of a reloaded config. Existing buckets keep
+// their tokens and are refilled at the new rate from now on.
+func (l *Limiter) Apply(change config.Change) {
+	if !change.Changed("ratelimit.default") && !change.Changed("ratelimit.groups") {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.def = change.New.RateLimit.Default
+	l.groups = change.New.RateLimit.Groups
+	if o, ok := l.store.(ruleObserver); ok {
+		o.applyRules(l.def, l.groups)
+	}
+}
+
+// rule returns the rule for group, falling back to the default
+func (l *Limiter) rule(group string) config.RateLimitRule {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if rule, ok := l.groups[group]; ok {
+		return rule
+	}
+	return l.def
+}
+
+// Limit returns middleware that counts requests against the bucket of
+// group. It must run after auth.Authenticate to key by principal.
+// Store failures are logged and the request is let through: an outage
+// of the limiter should not become an outage of the API.
+func (l *Limiter) Limit(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !l.enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rule := l.rule(group)
+			key := group + ":" + clientKey(r, rule.KeyBy)
+
+			res, err := l.store.Take(r.Context(), key, LimitFrom(rule), l.now())
+			if err != nil {
+				logging.FromContext(r.Context()).Error().Err(err).Str("group", group).Msg("Rate limiter unavailable")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				logging.FromContext(r.Context()).Warn().
+					Str("group", group).
+					Str("key", key).
+					Msg("Rate limit exceeded")
+				apierror.Write(w, r, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited,
+					"rate limit exceeded, retry after "+h.Get("Retry-After")+" seconds"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the caller: the principal when keyBy is
+// "principal" and the caller is authenticated, the client IP otherwise.
+// middleware.RealIP has already replaced RemoteAddr with the forwarded
+// address when there is one.
+func clientKey(r *http.Request, keyBy string) string {
+	if keyBy == "principal" {
+		if p := auth.PrincipalFrom(r.Context()); p != nil {
+			if p.APIKeyID != 0 {
+				return "key:" + strconv.FormatUint(uint64(p.APIKeyID), 10)
+			}
+			return "user:" + strconv.FormatUint(uint64(p.UserID), 10)
+		}
+	}
+
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return "ip:" + ip
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
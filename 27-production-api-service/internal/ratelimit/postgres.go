@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"example.com/production-api/internal/config"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pruneInterval is how often idle buckets are deleted
+const pruneInterval = 10 * time.Minute
+
+// bucketRow is a row of rate_limit_buckets
+type bucketRow struct {
+	Key       string `gorm:"primaryKey"`
+	Tokens    float64
+	UpdatedAt time.Time `gorm:"autoUpdateTime:false"`
+}
+
+func (bucketRow) TableName() string {
+	return "rate_limit_buckets"
+}
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so every
+// replica draws from the same bucket
+type PostgresStore struct {
+	db *gorm.DB
+
+	// maxIdle is the longest fill time of the current rules; buckets idle
+	// for longer are full and can be pruned
+	maxIdle atomic.Int64
+}
+
+// NewPostgresStore creates a Postgres-backed store and prunes buckets
+// that have been idle long enough to be full again
+func NewPostgresStore(lc fx.Lifecycle, db *gorm.DB, cfg *config.Config, logger zerolog.Logger) Store {
+	s := &PostgresStore{db: db}
+	s.applyRules(cfg.RateLimit.Default, cfg.RateLimit.Groups)
+
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go s.pruneLoop(done, logger)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(done)
+			return nil
+		},
+	})
+	return s
+}
+
+// Take implements Store. The bucket row is locked for the duration of
+// the update so concurrent requests from several replicas are counted
+// exactly once.
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	var res Result
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tokens, last := float64(limit.Burst), now
+
+		var row bucketRow
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).Take(&row).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// Two replicas creating the same bucket at once may both start
+			// from full; the upsert below keeps one of them
+		case err != nil:
+			return err
+		default:
+			tokens, last = row.Tokens, row.UpdatedAt
+		}
+
+		tokens, res = take(tokens, last, now, limit)
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"tokens", "updated_at"}),
+		}).Create(&bucketRow{Key: key, Tokens: tokens, UpdatedAt: now}).Error
+	})
+	return res, err
+}
+
+// applyRules implements ruleObserver
+func (s *PostgresStore) applyRules(def config.RateLimitRule, groups map[string]config.RateLimitRule) {
+	maxIdle := LimitFrom(def).fillTime()
+	for _, rule := range groups {
+		if d := LimitFrom(rule).fillTime(); d > maxIdle {
+			maxIdle = d
+		}
+	}
+	s.maxIdle.Store(int64(maxIdle))
+}
+
+func (s *PostgresStore) pruneLoop(done <-chan struct{}, logger zerolog.Logger) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			maxIdle := time.Duration(s.maxIdle.Load())
+			result := s.db.Where("updated_at < ?", now.Add(-maxIdle)).Delete(&bucketRow{})
+			if result.Error != nil {
+				logger.Error().Err(result.Error).Msg("Failed to prune rate limit buckets")
+				continue
+			}
+			logger.Debug().Int64("deleted", result.RowsAffected).Msg("Pruned rate limit buckets")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Package ratelimit throttles clients with token buckets. Each route
+// group has its own bucket per client; clients are identified by IP or
+// by authenticated principal. Buckets live in a Store, so replicas can
+// share them through Postgres.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/repository"
+	"math"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Stores accepted in ratelimit.store
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// Limit is the shape of one token bucket
+type Limit struct {
+	// Rate is the refill rate in tokens per second
+	Rate float64
+	// Burst is the bucket capacity
+	Burst int
+}
+
+// LimitFrom converts a configured rule into a bucket shape. Burst
+// defaults to Requests.
+func LimitFrom(rule config.RateLimitRule) Limit {
+	burst := rule.Burst
+	if burst <= 0 {
+		burst = rule.Requests
+	}
+	return Limit{
+		Rate:  float64(rule.Requests) / rule.Per.Seconds(),
+		Burst: burst,
+	}
+}
+
+// fillTime is how long an empty bucket takes to fill up
+func (l Limit) fillTime() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Result describes the bucket after a request was counted
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait for the next token when denied
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store holds token buckets
+type Store interface {
+	// Take refills bucket key up to now and removes one token if there is
+	// one
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// ruleObserver is implemented by stores whose housekeeping depends on
+// the rules; the Limiter tells them about rules changed on reload
+type ruleObserver interface {
+	applyRules(def config.RateLimitRule, groups map[string]config.RateLimitRule)
+}
+
+// take applies the token-bucket rule to a bucket that held tokens at
+// last and returns the new token count
+func take(tokens float64, last, now time.Time, l Limit) (float64, Result) {
+	if elapsed := now.Sub(last); elapsed > 0 {
+		tokens = math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.Rate)
+	}
+
+	res := Result{Limit: l.Burst}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / l.Rate)
+	}
+	res.Remaining = int(tokens)
+	res.Reset = seconds((float64(l.Burst) - tokens) / l.Rate)
+	return tokens, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Module provides the limiter with the store selected in configuration
+func Module(cfg *config.Config) fx.Option {
+	rl := cfg.RateLimit
+	switch {
+	case !rl.Enabled || rl.Store == StoreMemory:
+		return fx.Options(
+			fx.Provide(func() Store { return NewMemoryStore() }),
+			fx.Provide(NewLimiter),
+		)
+	case rl.Store == StorePostgres && cfg.Database.Driver == repository.DriverMemory:
+		return fx.Error(errors.New("ratelimit: the postgres store needs database.driver postgres"))
+	case rl.Store == StorePostgres:
+		return fx.Options(
+			fx.Provide(NewPostgresStore),
+			fx.Provide(NewLimiter),
+		)
+	}
+	return fx.Error(errors.New("ratelimit: unknown store " + rl.Store))
+}
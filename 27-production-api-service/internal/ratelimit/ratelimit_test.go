@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2} // one token per second, two at most
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		at            time.Duration
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{0, true, 1},
+		{0, true, 0},
+		{0, false, 0},
+		{500 * time.Millisecond, false, 0},
+		{time.Second, true, 0},
+		{10 * time.Second, true, 1}, // refill is capped at the burst
+	}
+
+	for i, s := range steps {
+		res, err := store.Take(context.Background(), "k", limit, start.Add(s.at))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if res.Allowed != s.wantAllowed || res.Remaining != s.wantRemaining {
+			t.Errorf("step %d at %s: allowed=%v remaining=%d; want %v %d",
+				i, s.at, res.Allowed, res.Remaining, s.wantAllowed, s.wantRemaining)
+		}
+		if !res.Allowed && res.RetryAfter <= 0 {
+			t.Errorf("step %d: denied without RetryAfter", i)
+		}
+	}
+}
+
+func newTestLimiter(rule config.RateLimitRule) (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := &config.Config{RateLimit: config.RateLimitConfig{
+		Enabled: true,
+		Default: config.RateLimitRule{Requests: 1000, Per: time.Minute, KeyBy: "ip"},
+		Groups:  map[string]config.RateLimitRule{"users": rule},
+	}}
+	l := NewLimiter(cfg, NewMemoryStore())
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimitHeadersAnd429(t *testing.T) {
+	l, now := newTestLimiter(config.RateLimitRule{Requests: 2, Per: time.Minute, KeyBy: "ip"})
+	h := l.Limit("users")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/users", nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	send("10.0.0.1")
+	w := send("10.0.0.1")
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != "0" || w.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Fatalf("second request: status %d, headers %v", w.Code, w.Header())
+	}
+
+	w = send("10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status = %d; want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q; want 30", got)
+	}
+
+	if w := send("10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("other IP: status = %d; want 200", w.Code)
+	}
+
+	*now = now.Add(30 * time.Second)
+	if w := send("10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("after Retry-After: status = %d; want 200", w.Code)
+	}
+}
+
+func TestLimitKeysByPrincipal(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimitRule{Requests: 1, Per: time.Minute, KeyBy: "principal"})
+	h := l.Limit("users")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(userID uint) int {
+		r := httptest.NewRequest("GET", "/api/users", nil)
+		r.RemoteAddr = "10.0.0.1:1234" // same NAT gateway for everyone
+		if userID != 0 {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{UserID: userID}))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if send(1) != http.StatusOK || send(2) != http.StatusOK {
+		t.Error("different users behind one IP share a bucket")
+	}
+	if got := send(1); got != http.StatusTooManyRequests {
+		t.Errorf("user 1 again: status = %d; want 429", got)
+	}
+	if got := send(0); got != http.StatusOK {
+		t.Errorf("anonymous caller: status = %d; want 200 from the IP bucket", got)
+	}
+}
+
+func TestApplyReloadedRules(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimitRule{Requests: 1, Per: time.Minute, KeyBy: "ip"})
+	h := l.Limit("users")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/users", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	send()
+	if w := send(); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status = %d; want 429", w.Code)
+	}
+
+	next := &config.Config{RateLimit: config.RateLimitConfig{
+		Default: config.RateLimitRule{Requests: 1000, Per: time.Minute, KeyBy: "ip"},
+		Groups:  map[string]config.RateLimitRule{"users": {Requests: 10, Per: time.Minute, Burst: 10, KeyBy: "ip"}},
+	}}
+	l.Apply(config.Change{New: next, Keys: []string{"ratelimit.groups.users.burst"}})
+
+	// The emptied bucket refills at the new rate and up to the new burst
+	w := send()
+	if w.Header().Get("X-RateLimit-Limit") != "10" {
+		t.Errorf("limit after reload = %q; want 10", w.Header().Get("X-RateLimit-Limit"))
+	}
+}
+
+func TestReloadUpdatesPruneAge(t *testing.T) {
+	cfg := &config.Config{RateLimit: config.RateLimitConfig{
+		Enabled: true,
+		Default: config.RateLimitRule{Requests: 60, Per: time.Minute},
+	}}
+	store := &PostgresStore{}
+	store.applyRules(cfg.RateLimit.Default, cfg.RateLimit.Groups)
+	l := NewLimiter(cfg, store)
+
+	// A slower refill keeps buckets from being full for longer, so they
+	// must not be pruned at the old age
+	next := &config.Config{RateLimit: config.RateLimitConfig{
+		Default: config.RateLimitRule{Requests: 60, Per: time.Minute},
+		Groups:  map[string]config.RateLimitRule{"auth": {Requests: 5, Per: time.Hour}},
+	}}
+	l.Apply(config.Change{New: next, Keys: []string{"ratelimit.groups.auth.requests"}})
+
+	if got := time.Duration(store.maxIdle.Load()); got != time.Hour {
+		t.Errorf("prune age after reload = %s; want 1h", got)
+	}
+}
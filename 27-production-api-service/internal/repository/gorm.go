@@ -0,0 +1,401 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"example.com/production-api/internal/database"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/pagination"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// translate adds the repository sentinel to Postgres constraint errors
+// while keeping the original error in the chain for logging
+func translate(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return errors.Join(ErrDuplicate, err)
+		case "23503":
+			return errors.Join(ErrForeignKey, err)
+		}
+	}
+	return err
+}
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository creates a GORM-backed user repository
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+// scoped includes soft-deleted users when q asks for them
+func (r *userRepository) scoped(ctx context.Context, q UserQuery) *gorm.DB {
+	query := database.Conn(ctx, r.db).Model(&models.User{})
+	if q.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	return query
+}
+
+func (r *userRepository) List(ctx context.Context, q UserQuery, req *pagination.Request) ([]models.User, *pagination.Page, error) {
+	var users []models.User
+	page, err := pagination.Find(r.scoped(ctx, q), req, &users)
+	return users, page, err
+}
+
+func (r *userRepository) Get(ctx context.Context, id uint, q UserQuery) (*models.User, error) {
+	var user models.User
+	if err := r.scoped(ctx, q).First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := database.Conn(ctx, r.db).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return translate(database.Conn(ctx, r.db).Omit(clause.Associations).Create(user).Error)
+}
+
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	now := time.Now()
+	result := database.Conn(ctx, r.db).
+		Model(&models.User{}).
+		Where("id = ? AND version = ?", user.ID, user.Version).
+		Updates(map[string]interface{}{
+			"name":       user.Name,
+			"email":      user.Email,
+			"updated_at": now,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if err := versioned(database.Conn(ctx, r.db), &models.User{}, user.ID, result); err != nil {
+		return err
+	}
+	user.UpdatedAt = now
+	user.Version++
+	return nil
+}
+
+func (r *userRepository) Delete(ctx context.Context, id uint, version uint) error {
+	// The posts check is part of the statement so a post created
+	// concurrently cannot be orphaned
+	result := withVersion(database.Conn(ctx, r.db), version).
+		Model(&models.User{}).
+		Where("id = ?", id).
+		Where("NOT EXISTS (SELECT 1 FROM posts WHERE posts.user_id = users.id AND posts.deleted_at IS NULL)").
+		Updates(map[string]interface{}{
+			"deleted_at": time.Now(),
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error == nil && result.RowsAffected == 0 {
+		var posts int64
+		if err := database.Conn(ctx, r.db).Model(&models.Post{}).Where("user_id = ?", id).Count(&posts).Error; err != nil {
+			return err
+		}
+		if posts > 0 {
+			return ErrForeignKey
+		}
+	}
+	return versioned(database.Conn(ctx, r.db), &models.User{}, id, result)
+}
+
+func (r *userRepository) Restore(ctx context.Context, id uint) (*models.User, error) {
+	// Read the restored user back in the same transaction, so the read
+	// sees the write even with replicas lagging behind
+	var user *models.User
+	err := database.WithTx(ctx, r.db, func(ctx context.Context) error {
+		result := database.Conn(ctx, r.db).
+			Unscoped().
+			Model(&models.User{}).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			Updates(map[string]interface{}{
+				"deleted_at": nil,
+				"version":    gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return translate(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		var err error
+		user, err = r.Get(ctx, id, UserQuery{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *userRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	// Users whose posts are still within the window wait for them, so
+	// the cascade never purges a post early
+	result := database.Conn(ctx, r.db).
+		Unscoped().
+		Where("deleted_at < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM posts WHERE posts.user_id = users.id)").
+		Delete(&models.User{})
+	return result.RowsAffected, result.Error
+}
+
+// withVersion restricts a write to one version of the row; zero means any
+func withVersion(db *gorm.DB, version uint) *gorm.DB {
+	if version == 0 {
+		return db
+	}
+	return db.Where("version = ?", version)
+}
+
+// versioned interprets the result of a version-checked write: when no
+// row changed, the row is either gone or at another version. The check
+// reads the primary, where the row the write missed is up to date.
+func versioned(db *gorm.DB, model interface{}, id uint, result *gorm.DB) error {
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	db = db.WithContext(database.Primary(db.Statement.Context))
+	if err := db.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+type postRepository struct {
+	db *gorm.DB
+}
+
+// NewPostRepository creates a GORM-backed post repository
+func NewPostRepository(db *gorm.DB) PostRepository {
+	return &postRepository{db: db}
+}
+
+// scoped applies the author and visibility restrictions to a post query
+func (r *postRepository) scoped(ctx context.Context, q PostQuery) *gorm.DB {
+	query := database.Conn(ctx, r.db).Model(&models.Post{})
+	if q.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	if q.UserID != 0 {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if q.PublishedOnly {
+		if q.DraftsBy != 0 {
+			query = query.Where("(published = ? OR user_id = ?)", true, q.DraftsBy)
+		} else {
+			query = query.Where("published = ?", true)
+		}
+	}
+	return query
+}
+
+// includes preloads the author when requested
+func includes(q PostQuery) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch {
+		case q.IncludeUser && q.IncludeDeleted:
+			return db.Preload("User", func(db *gorm.DB) *gorm.DB { return db.Unscoped() })
+		case q.IncludeUser:
+			return db.Preload("User")
+		}
+		return db
+	}
+}
+
+func (r *postRepository) List(ctx context.Context, q PostQuery, req *pagination.Request) ([]models.Post, *pagination.Page, error) {
+	var posts []models.Post
+	page, err := pagination.Find(r.scoped(ctx, q), req, &posts, includes(q))
+	return posts, page, err
+}
+
+func (r *postRepository) Get(ctx context.Context, id uint, q PostQuery) (*models.Post, error) {
+	var post models.Post
+	if err := r.scoped(ctx, q).Scopes(includes(q)).First(&post, id).Error; err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (r *postRepository) Create(ctx context.Context, post *models.Post) error {
+	return translate(database.Conn(ctx, r.db).Omit("User").Create(post).Error)
+}
+
+func (r *postRepository) Update(ctx context.Context, post *models.Post) error {
+	now := time.Now()
+	result := database.Conn(ctx, r.db).
+		Model(&models.Post{}).
+		Where("id = ? AND version = ?", post.ID, post.Version).
+		Updates(map[string]interface{}{
+			"title":      post.Title,
+			"content":    post.Content,
+			"published":  post.Published,
+			"updated_at": now,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if err := versioned(database.Conn(ctx, r.db), &models.Post{}, post.ID, result); err != nil {
+		return err
+	}
+	post.UpdatedAt = now
+	post.Version++
+	return nil
+}
+
+func (r *postRepository) Delete(ctx context.Context, id uint, version uint) error {
+	result := withVersion(database.Conn(ctx, r.db), version).
+		Model(&models.Post{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"deleted_at": time.Now(),
+			"version":    gorm.Expr("version + 1"),
+		})
+	return versioned(database.Conn(ctx, r.db), &models.Post{}, id, result)
+}
+
+func (r *postRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := database.Conn(ctx, r.db).
+		Unscoped().
+		Where("deleted_at < ?", cutoff).
+		Delete(&models.Post{})
+	return result.RowsAffected, result.Error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository creates a GORM-backed refresh token repository
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	return translate(database.Conn(ctx, r.db).Create(token).Error)
+}
+
+// GetByHash reads the primary: a client may refresh with a token issued
+// a moment ago
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := database.Conn(database.Primary(ctx), r.db).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) Revoke(ctx context.Context, id uint) error {
+	result := database.Conn(ctx, r.db).
+		Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, family string) error {
+	return database.Conn(ctx, r.db).
+		Model(&models.RefreshToken{}).
+		Where("family = ? AND revoked_at IS NULL", family).
+		Update("revoked_at", time.Now()).Error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a GORM-backed API key repository
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) List(ctx context.Context, req *pagination.Request) ([]models.APIKey, *pagination.Page, error) {
+	var keys []models.APIKey
+	page, err := pagination.Find(database.Conn(ctx, r.db).Model(&models.APIKey{}), req, &keys)
+	return keys, page, err
+}
+
+func (r *apiKeyRepository) Get(ctx context.Context, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := database.Conn(ctx, r.db).First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByHash reads the primary so a key works as soon as it is created
+// or rotated
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := database.Conn(database.Primary(ctx), r.db).Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return translate(database.Conn(ctx, r.db).Create(key).Error)
+}
+
+func (r *apiKeyRepository) SetExpiry(ctx context.Context, id uint, at time.Time) error {
+	return r.update(ctx, id, "expires_at", at)
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id uint, at time.Time) error {
+	return r.update(ctx, id, "revoked_at", at)
+}
+
+func (r *apiKeyRepository) Touch(ctx context.Context, id uint, at time.Time) error {
+	return r.update(ctx, id, "last_used_at", at)
+}
+
+func (r *apiKeyRepository) update(ctx context.Context, id uint, column string, value interface{}) error {
+	result := database.Conn(ctx, r.db).Model(&models.APIKey{}).Where("id = ?", id).Update(column, value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type transactor struct {
+	db *gorm.DB
+}
+
+// NewTransactor creates a Transactor running Postgres transactions
+func NewTransactor(db *gorm.DB) Transactor {
+	return &transactor{db: db}
+}
+
+func (t *transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return database.WithTx(ctx, t.db, fn)
+}
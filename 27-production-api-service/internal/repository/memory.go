@@ -0,0 +1,543 @@
+package repository
+
+import (
+	"context"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/pagination"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MemoryStore keeps users, posts, tokens and API keys in process memory.
+// It enforces the same unique email and foreign key rules as the
+// Postgres schema.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	users         map[uint]models.User
+	posts         map[uint]models.Post
+	refreshTokens map[uint]models.RefreshToken
+	apiKeys       map[uint]models.APIKey
+	nextID        uint
+
+	observe ChangeObserver
+}
+
+// ChangeObserver is told about every user and post write to a
+// MemoryStore, as GORM callbacks are for Postgres. before is nil for
+// creates and after is nil when a record is purged. It is called with the
+// store locked.
+type ChangeObserver func(ctx context.Context, table string, before, after interface{})
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users: make(map[uint]models.User),
+		posts: make(map[uint]models.Post),
+
+		refreshTokens: make(map[uint]models.RefreshToken),
+		apiKeys:       make(map[uint]models.APIKey),
+	}
+}
+
+// Observe registers fn to be told about writes
+func (s *MemoryStore) Observe(fn ChangeObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observe = fn
+}
+
+func (s *MemoryStore) id() uint {
+	s.nextID++
+	return s.nextID
+}
+
+// changed must be called with the store lock held
+func (s *MemoryStore) changed(ctx context.Context, table string, before, after interface{}) {
+	if s.observe != nil {
+		s.observe(ctx, table, before, after)
+	}
+}
+
+type memoryUserRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryUserRepository creates a user repository backed by store
+func NewMemoryUserRepository(store *MemoryStore) UserRepository {
+	return &memoryUserRepository{store: store}
+}
+
+func (r *memoryUserRepository) List(ctx context.Context, q UserQuery, req *pagination.Request) ([]models.User, *pagination.Page, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	users := make([]models.User, 0, len(r.store.users))
+	for _, u := range r.store.users {
+		if !u.DeletedAt.Valid || q.IncludeDeleted {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	return pagination.Slice(users, req)
+}
+
+func (r *memoryUserRepository) Get(ctx context.Context, id uint, q UserQuery) (*models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	user, ok := r.store.users[id]
+	if !ok || (user.DeletedAt.Valid && !q.IncludeDeleted) {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, u := range r.store.users {
+		if u.Email == email && !u.DeletedAt.Valid {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.emailTaken(user.Email, 0) {
+		return ErrDuplicate
+	}
+
+	now := time.Now()
+	user.ID = r.store.id()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Version = 1
+	user.DeletedAt = gorm.DeletedAt{}
+	user.Posts = nil
+	r.store.users[user.ID] = *user
+	r.store.changed(ctx, "users", nil, *user)
+	return nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.users[user.ID]
+	if !ok || existing.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if existing.Version != user.Version {
+		return ErrVersionConflict
+	}
+	if r.emailTaken(user.Email, user.ID) {
+		return ErrDuplicate
+	}
+
+	before := existing
+	existing.Name = user.Name
+	existing.Email = user.Email
+	existing.UpdatedAt = time.Now()
+	existing.Version++
+	r.store.users[user.ID] = existing
+	r.store.changed(ctx, "users", before, existing)
+	*user = existing
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id uint, version uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if version != 0 && user.Version != version {
+		return ErrVersionConflict
+	}
+	for _, p := range r.store.posts {
+		if p.UserID == id && !p.DeletedAt.Valid {
+			return ErrForeignKey
+		}
+	}
+
+	before := user
+	user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	user.Version++
+	r.store.users[id] = user
+	r.store.changed(ctx, "users", before, user)
+	return nil
+}
+
+func (r *memoryUserRepository) Restore(ctx context.Context, id uint) (*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[id]
+	if !ok || !user.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	if r.emailTaken(user.Email, id) {
+		return nil, ErrDuplicate
+	}
+
+	before := user
+	user.DeletedAt = gorm.DeletedAt{}
+	user.Version++
+	r.store.users[id] = user
+	r.store.changed(ctx, "users", before, user)
+	return &user, nil
+}
+
+func (r *memoryUserRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	hasPosts := make(map[uint]bool)
+	for _, p := range r.store.posts {
+		hasPosts[p.UserID] = true
+	}
+
+	var n int64
+	for id, u := range r.store.users {
+		if !u.DeletedAt.Valid || !u.DeletedAt.Time.Before(cutoff) || hasPosts[id] {
+			continue
+		}
+		delete(r.store.users, id)
+		r.store.changed(ctx, "users", u, nil)
+		n++
+
+		// Cascade like the foreign keys in Postgres
+		for tid, t := range r.store.refreshTokens {
+			if t.UserID == id {
+				delete(r.store.refreshTokens, tid)
+			}
+		}
+		for kid, k := range r.store.apiKeys {
+			if k.UserID == id {
+				delete(r.store.apiKeys, kid)
+			}
+		}
+	}
+	return n, nil
+}
+
+// emailTaken reports whether a live user other than exceptID has email.
+// It must be called with the store lock held.
+func (r *memoryUserRepository) emailTaken(email string, exceptID uint) bool {
+	for _, u := range r.store.users {
+		if u.Email == email && u.ID != exceptID && !u.DeletedAt.Valid {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryPostRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryPostRepository creates a post repository backed by store
+func NewMemoryPostRepository(store *MemoryStore) PostRepository {
+	return &memoryPostRepository{store: store}
+}
+
+func (r *memoryPostRepository) List(ctx context.Context, q PostQuery, req *pagination.Request) ([]models.Post, *pagination.Page, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	posts := make([]models.Post, 0, len(r.store.posts))
+	for _, p := range r.store.posts {
+		if (q.UserID == 0 || p.UserID == q.UserID) && q.Visible(p) {
+			posts = append(posts, r.withUser(p, q))
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+
+	return pagination.Slice(posts, req)
+}
+
+func (r *memoryPostRepository) Get(ctx context.Context, id uint, q PostQuery) (*models.Post, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	post, ok := r.store.posts[id]
+	if !ok || (q.UserID != 0 && post.UserID != q.UserID) || !q.Visible(post) {
+		return nil, ErrNotFound
+	}
+	post = r.withUser(post, q)
+	return &post, nil
+}
+
+func (r *memoryPostRepository) Create(ctx context.Context, post *models.Post) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if u, ok := r.store.users[post.UserID]; !ok || u.DeletedAt.Valid {
+		return ErrForeignKey
+	}
+
+	now := time.Now()
+	post.ID = r.store.id()
+	post.CreatedAt = now
+	post.UpdatedAt = now
+	post.Version = 1
+	post.DeletedAt = gorm.DeletedAt{}
+	post.User = nil
+	r.store.posts[post.ID] = *post
+	r.store.changed(ctx, "posts", nil, *post)
+	return nil
+}
+
+func (r *memoryPostRepository) Update(ctx context.Context, post *models.Post) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.posts[post.ID]
+	if !ok || existing.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if existing.Version != post.Version {
+		return ErrVersionConflict
+	}
+
+	before := existing
+	existing.Title = post.Title
+	existing.Content = post.Content
+	existing.Published = post.Published
+	existing.UpdatedAt = time.Now()
+	existing.Version++
+	r.store.posts[post.ID] = existing
+	r.store.changed(ctx, "posts", before, existing)
+
+	user := post.User
+	*post = existing
+	post.User = user
+	return nil
+}
+
+func (r *memoryPostRepository) Delete(ctx context.Context, id uint, version uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	post, ok := r.store.posts[id]
+	if !ok || post.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if version != 0 && post.Version != version {
+		return ErrVersionConflict
+	}
+
+	before := post
+	post.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	post.Version++
+	r.store.posts[id] = post
+	r.store.changed(ctx, "posts", before, post)
+	return nil
+}
+
+func (r *memoryPostRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var n int64
+	for id, p := range r.store.posts {
+		if p.DeletedAt.Valid && p.DeletedAt.Time.Before(cutoff) {
+			delete(r.store.posts, id)
+			r.store.changed(ctx, "posts", p, nil)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// withUser must be called with the store lock held
+func (r *memoryPostRepository) withUser(p models.Post, q PostQuery) models.Post {
+	if q.IncludeUser {
+		if u, ok := r.store.users[p.UserID]; ok && (!u.DeletedAt.Valid || q.IncludeDeleted) {
+			p.User = &u
+		}
+	}
+	return p
+}
+
+type memoryRefreshTokenRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryRefreshTokenRepository creates a refresh token repository
+// backed by store
+func NewMemoryRefreshTokenRepository(store *MemoryStore) RefreshTokenRepository {
+	return &memoryRefreshTokenRepository{store: store}
+}
+
+func (r *memoryRefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[token.UserID]; !ok {
+		return ErrForeignKey
+	}
+	for _, t := range r.store.refreshTokens {
+		if t.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+
+	token.ID = r.store.id()
+	token.CreatedAt = time.Now()
+	r.store.refreshTokens[token.ID] = *token
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, t := range r.store.refreshTokens {
+		if t.TokenHash == hash {
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryRefreshTokenRepository) Revoke(ctx context.Context, id uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	t, ok := r.store.refreshTokens[id]
+	if !ok || t.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	t.RevokedAt = &now
+	r.store.refreshTokens[id] = t
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) RevokeFamily(ctx context.Context, family string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range r.store.refreshTokens {
+		if t.Family == family && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			r.store.refreshTokens[id] = t
+		}
+	}
+	return nil
+}
+
+type memoryAPIKeyRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryAPIKeyRepository creates an API key repository backed by store
+func NewMemoryAPIKeyRepository(store *MemoryStore) APIKeyRepository {
+	return &memoryAPIKeyRepository{store: store}
+}
+
+func (r *memoryAPIKeyRepository) List(ctx context.Context, req *pagination.Request) ([]models.APIKey, *pagination.Page, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(r.store.apiKeys))
+	for _, k := range r.store.apiKeys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return pagination.Slice(keys, req)
+}
+
+func (r *memoryAPIKeyRepository) Get(ctx context.Context, id uint) (*models.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	key, ok := r.store.apiKeys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &key, nil
+}
+
+func (r *memoryAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, k := range r.store.apiKeys {
+		if k.KeyHash == hash {
+			return &k, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[key.UserID]; !ok {
+		return ErrForeignKey
+	}
+	for _, k := range r.store.apiKeys {
+		if k.KeyHash == key.KeyHash {
+			return ErrDuplicate
+		}
+	}
+
+	key.ID = r.store.id()
+	key.CreatedAt = time.Now()
+	r.store.apiKeys[key.ID] = *key
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) SetExpiry(ctx context.Context, id uint, at time.Time) error {
+	return r.update(id, func(k *models.APIKey) { k.ExpiresAt = &at })
+}
+
+func (r *memoryAPIKeyRepository) Revoke(ctx context.Context, id uint, at time.Time) error {
+	return r.update(id, func(k *models.APIKey) { k.RevokedAt = &at })
+}
+
+func (r *memoryAPIKeyRepository) Touch(ctx context.Context, id uint, at time.Time) error {
+	return r.update(id, func(k *models.APIKey) { k.LastUsedAt = &at })
+}
+
+func (r *memoryAPIKeyRepository) update(id uint, fn func(*models.APIKey)) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key, ok := r.store.apiKeys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(&key)
+	r.store.apiKeys[id] = key
+	return nil
+}
+
+type memoryTransactor struct{}
+
+// NewMemoryTransactor creates a Transactor for the memory store. Each
+// memory write applies on its own, so fn runs directly and a failing fn
+// does not undo the writes it already made.
+func NewMemoryTransactor() Transactor {
+	return memoryTransactor{}
+}
+
+func (memoryTransactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,157 @@
+// Package repository provides data access for the domain models behind
+// interfaces, with GORM and in-memory implementations
+package repository
+
+import (
+	"context"
+	"errors"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/database"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/pagination"
+	"time"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+// Errors returned by every implementation. They alias GORM's sentinels so
+// callers can use errors.Is regardless of the backing store.
+var (
+	ErrNotFound   = gorm.ErrRecordNotFound
+	ErrDuplicate  = gorm.ErrDuplicatedKey
+	ErrForeignKey = gorm.ErrForeignKeyViolated
+
+	// ErrVersionConflict reports a write against a row that changed
+	// since it was read
+	ErrVersionConflict = errors.New("repository: version conflict")
+)
+
+// UserQuery narrows user lookups
+type UserQuery struct {
+	// IncludeDeleted also returns soft-deleted users
+	IncludeDeleted bool
+}
+
+// UserRepository stores users. Deleting a user only marks it deleted;
+// deleted users are invisible to every method except those taking a
+// UserQuery, and Purge removes them for good.
+type UserRepository interface {
+	List(ctx context.Context, q UserQuery, req *pagination.Request) ([]models.User, *pagination.Page, error)
+	Get(ctx context.Context, id uint, q UserQuery) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	// Update saves user if it is still at user.Version and increments
+	// the version
+	Update(ctx context.Context, user *models.User) error
+	// Delete soft-deletes a user at version, or at any version if it is
+	// zero. Users with posts that are not deleted return ErrForeignKey.
+	Delete(ctx context.Context, id uint, version uint) error
+	// Restore undeletes a user. It returns ErrNotFound unless the user
+	// is deleted, and ErrDuplicate if its email was taken meanwhile.
+	Restore(ctx context.Context, id uint) (*models.User, error)
+	// Purge permanently removes users deleted before cutoff that have no
+	// posts left
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// PostQuery narrows post lookups
+type PostQuery struct {
+	// UserID restricts results to one author; zero means any author
+	UserID uint
+	// IncludeUser preloads each post's author
+	IncludeUser bool
+	// PublishedOnly hides drafts, except those written by DraftsBy
+	PublishedOnly bool
+	DraftsBy      uint
+	// IncludeDeleted also returns soft-deleted posts
+	IncludeDeleted bool
+}
+
+// Visible reports whether post is within the visibility limits of q
+func (q PostQuery) Visible(post models.Post) bool {
+	if post.DeletedAt.Valid && !q.IncludeDeleted {
+		return false
+	}
+	return !q.PublishedOnly || post.Published || (q.DraftsBy != 0 && post.UserID == q.DraftsBy)
+}
+
+// PostRepository stores posts
+type PostRepository interface {
+	List(ctx context.Context, q PostQuery, req *pagination.Request) ([]models.Post, *pagination.Page, error)
+	Get(ctx context.Context, id uint, q PostQuery) (*models.Post, error)
+	Create(ctx context.Context, post *models.Post) error
+	// Update saves post if it is still at post.Version and increments
+	// the version
+	Update(ctx context.Context, post *models.Post) error
+	// Delete soft-deletes a post at version, or at any version if it is
+	// zero
+	Delete(ctx context.Context, id uint, version uint) error
+	// Purge permanently removes posts deleted before cutoff
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// RefreshTokenRepository stores hashed refresh tokens
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// Revoke marks an active token as used. It returns ErrNotFound if the
+	// token does not exist or was already revoked, so two concurrent
+	// refreshes with the same token cannot both succeed.
+	Revoke(ctx context.Context, id uint) error
+	// RevokeFamily revokes every active token issued from one login
+	RevokeFamily(ctx context.Context, family string) error
+}
+
+// APIKeyRepository stores hashed API keys
+type APIKeyRepository interface {
+	List(ctx context.Context, req *pagination.Request) ([]models.APIKey, *pagination.Page, error)
+	Get(ctx context.Context, id uint) (*models.APIKey, error)
+	GetByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	Create(ctx context.Context, key *models.APIKey) error
+	// SetExpiry changes when a key stops working
+	SetExpiry(ctx context.Context, id uint, at time.Time) error
+	// Revoke disables a key immediately
+	Revoke(ctx context.Context, id uint, at time.Time) error
+	// Touch records that a key was used
+	Touch(ctx context.Context, id uint, at time.Time) error
+}
+
+// Transactor runs work that must succeed or fail as a whole. Repository
+// calls made with the context passed to fn take part in the transaction;
+// fn may run more than once, see database.WithTx.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Storage drivers accepted in database.driver
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+// Module selects the repository implementation from configuration.
+// The memory driver never opens a database connection, which makes it
+// suitable for tests and local experiments.
+func Module(cfg *config.Config) fx.Option {
+	if cfg.Database.Driver == DriverMemory {
+		return fx.Options(
+			fx.Provide(NewMemoryStore),
+			fx.Provide(NewMemoryUserRepository),
+			fx.Provide(NewMemoryPostRepository),
+			fx.Provide(NewMemoryRefreshTokenRepository),
+			fx.Provide(NewMemoryAPIKeyRepository),
+			fx.Provide(NewMemoryTransactor),
+		)
+	}
+
+	return fx.Options(
+		database.Module,
+		database.RequireSchema,
+		fx.Provide(NewUserRepository),
+		fx.Provide(NewPostRepository),
+		fx.Provide(NewRefreshTokenRepository),
+		fx.Provide(NewAPIKeyRepository),
+		fx.Provide(NewTransactor),
+	)
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/config"
+)
+
+// bodyLimits caps request bodies per route group
+type bodyLimits struct {
+	def    int64
+	groups map[string]int64
+}
+
+func newBodyLimits(cfg *config.Config) *bodyLimits {
+	return &bodyLimits{def: cfg.Server.MaxBodyBytes, groups: cfg.Server.BodyLimits}
+}
+
+// Limit returns middleware that caps bodies of group at its configured
+// size. Requests declaring a larger Content-Length are refused up front;
+// otherwise reading past the limit fails with *http.MaxBytesError, which
+// handlers report as 413.
+func (b *bodyLimits) Limit(group string) func(http.Handler) http.Handler {
+	limit, ok := b.groups[group]
+	if !ok {
+		limit = b.def
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > limit {
+				apierror.Write(w, r, apierror.TooLarge(limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/config"
+)
+
+func TestBodyLimit(t *testing.T) {
+	body := newBodyLimits(&config.Config{Server: config.ServerConfig{
+		MaxBodyBytes: 16,
+		BodyLimits:   map[string]int64{"auth": 4},
+	}})
+	// Echo the body like a handler decoding JSON would read it
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			apierror.Write(w, r, apierror.UnreadableBody(err))
+		}
+	})
+
+	tests := []struct {
+		name    string
+		group   string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{"under the default", "users", "0123456789", false, http.StatusOK},
+		{"over the default", "users", strings.Repeat("x", 17), false, http.StatusRequestEntityTooLarge},
+		{"over the group override", "auth", "01234", false, http.StatusRequestEntityTooLarge},
+		{"chunked over the limit", "auth", "01234", true, http.StatusRequestEntityTooLarge},
+		{"chunked under the limit", "auth", "0123", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			body.Limit(tt.group)(echo).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d; want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusRequestEntityTooLarge {
+				var p apierror.Problem
+				json.NewDecoder(w.Body).Decode(&p)
+				if p.Code != apierror.CodeTooLarge {
+					t.Errorf("code = %q; want %q", p.Code, apierror.CodeTooLarge)
+				}
+			}
+		})
+	}
+}
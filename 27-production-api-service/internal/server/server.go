@@ -2,15 +2,30 @@ package server
 
 import (
 	"context"
-	"example.com/production-api/internal/config"
-	"example.com/production-api/internal/handlers"
 	"fmt"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/fx"
+
+	"example.com/production-api/internal/apierror"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/certs"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/cors"
+	"example.com/production-api/internal/handlers"
+	"example.com/production-api/internal/health"
+	"example.com/production-api/internal/idempotency"
+	"example.com/production-api/internal/logging"
+	"example.com/production-api/internal/metrics"
+	"example.com/production-api/internal/policy"
+	"example.com/production-api/internal/ratelimit"
+	"example.com/production-api/internal/tracing"
 )
 
 // Server wraps the HTTP server
@@ -19,63 +34,207 @@ type Server struct {
 }
 
 // NewRouter creates the chi router with all routes
-func NewRouter(userHandler *handlers.UserHandler) chi.Router {
+func NewRouter(
+	userHandler *handlers.UserHandler,
+	postHandler *handlers.PostHandler,
+	authHandler *handlers.AuthHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	auditHandler *handlers.AuditHandler,
+	tokens *auth.TokenIssuer,
+	keys auth.KeyAuthenticator,
+	certAuth auth.CertAuthenticator,
+	pol *policy.Enforcer,
+	limiter *ratelimit.Limiter,
+	idem *idempotency.Middleware,
+	m *metrics.Metrics,
+	tp trace.TracerProvider,
+	probes *health.Probes,
+	c *cors.CORS,
+	cfg *config.Config,
+	logger zerolog.Logger,
+) chi.Router {
 	r := chi.NewRouter()
+	body := newBodyLimits(cfg)
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(m.Middleware)
+	r.Use(tracing.Middleware(tp))
+	r.Use(logging.Middleware(logger))
+	r.Use(c.Handler)
 	r.Use(middleware.Recoverer)
+	r.Use(auth.AuthenticateCert(certAuth), auth.Authenticate(tokens, keys))
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.NotFound("route not found"))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.New(http.StatusMethodNotAllowed, apierror.CodeNotAllowed, "method not allowed"))
+	})
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Production API Service"))
 	})
 
 	r.Route("/api", func(r chi.Router) {
-		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.Write([]byte("OK"))
+		// Probes for load balancers and orchestrators; /health is the
+		// liveness probe under its historical name
+		r.Route("/health", func(r chi.Router) {
+			r.Get("/", probes.Live)
+			r.Get("/live", probes.Live)
+			r.Get("/ready", probes.Readiness)
+			r.Get("/startup", probes.Startup)
+		})
+
+		// Auth responses carry tokens, so they are never stored for
+		// idempotent replay
+		r.Route("/auth", func(r chi.Router) {
+			r.Use(body.Limit("auth"), limiter.Limit("auth"))
+			r.Post("/register", authHandler.Register)
+			r.Post("/login", authHandler.Login)
+			r.Post("/refresh", authHandler.Refresh)
+			r.Post("/logout", authHandler.Logout)
 		})
 
+		// Post routes are mounted both at the top level and nested under
+		// a user. Reads are public but hide drafts from other callers.
+		posts := func(r chi.Router) {
+			r.Use(body.Limit("posts"), limiter.Limit("posts"), idem.Handler)
+			write := policy.RequireScope(auth.ScopePostsWrite)
+			owner := chi.Chain(pol.Require(policy.Admin, pol.PostOwner("postID")), write)
+
+			r.Get("/", postHandler.List)
+			r.Get("/{postID}", postHandler.Get)
+			r.With(auth.Require, write).Post("/", postHandler.Create)
+			r.With(owner...).Put("/{postID}", postHandler.Replace)
+			r.With(owner...).Patch("/{postID}", postHandler.Patch)
+			r.With(owner...).Delete("/{postID}", postHandler.Delete)
+			r.With(owner...).Post("/{postID}/publish", postHandler.Publish)
+			r.With(owner...).Post("/{postID}/unpublish", postHandler.Unpublish)
+		}
+
 		r.Route("/users", func(r chi.Router) {
-			r.Get("/", userHandler.List)
-			r.Post("/", userHandler.Create)
-			r.Get("/{id}", userHandler.Get)
-			r.Put("/{id}", userHandler.Update)
-			r.Delete("/{id}", userHandler.Delete)
+			write := policy.RequireScope(auth.ScopeUsersWrite)
+			self := chi.Chain(pol.Require(policy.Admin, policy.Self("id")), write)
+
+			r.Group(func(r chi.Router) {
+				r.Use(body.Limit("users"), limiter.Limit("users"), idem.Handler)
+				r.With(auth.Require).Get("/", userHandler.List)
+				r.With(auth.Require).Get("/{id}", userHandler.Get)
+				r.With(pol.Require(policy.Admin), write).Post("/", userHandler.Create)
+				r.With(self...).Put("/{id}", userHandler.Replace)
+				r.With(self...).Patch("/{id}", userHandler.Patch)
+				r.With(self...).Delete("/{id}", userHandler.Delete)
+				r.With(pol.Require(policy.Admin), write).Post("/{id}/restore", userHandler.Restore)
+			})
+			r.Route("/{id}/posts", posts)
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(body.Limit("admin"), limiter.Limit("admin"), idem.Handler)
+			r.Use(pol.Require(policy.Admin), policy.RequireScope(auth.ScopeAdmin))
+
+			r.Route("/api-keys", func(r chi.Router) {
+				r.Get("/", apiKeyHandler.List)
+				r.Post("/", apiKeyHandler.Create)
+				r.Get("/{keyID}", apiKeyHandler.Get)
+				r.Post("/{keyID}/rotate", apiKeyHandler.Rotate)
+				r.Delete("/{keyID}", apiKeyHandler.Revoke)
+			})
+		})
+
+		r.Route("/audit", func(r chi.Router) {
+			r.Use(body.Limit("admin"), limiter.Limit("admin"))
+			r.Use(pol.Require(policy.Admin), policy.RequireScope(auth.ScopeAdmin))
+			r.Get("/", auditHandler.List)
 		})
+
+		r.Route("/posts", posts)
 	})
 
 	return r
 }
 
-// New creates HTTP server with lifecycle
-func New(lc fx.Lifecycle, cfg *config.Config, router chi.Router, logger zerolog.Logger) *Server {
+// New creates HTTP server with lifecycle. Its OnStop hook runs first and
+// fails readiness, then keeps serving for health.draindelay before
+// shutting down. In-flight requests get server.shutdowntimeout to finish. With
+// server.tls.certfile set it serves HTTPS and reloads the certificate
+// when its files change.
+func New(lc fx.Lifecycle, cfg *config.Config, router chi.Router, probes *health.Probes, logger zerolog.Logger) (*Server, error) {
 	srv := &Server{
 		server: &http.Server{
-			Addr:    ":" + cfg.Server.Port,
-			Handler: router,
+			Addr:              ":" + cfg.Server.Port,
+			Handler:           router,
+			ReadTimeout:       cfg.Server.ReadTimeout,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+			WriteTimeout:      cfg.Server.WriteTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+			MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
 		},
 	}
 
+	var reloader *certs.Reloader
+	if cfg.Server.TLS.CertFile != "" {
+		var err error
+		if reloader, err = certs.NewReloader(cfg.Server.TLS, logger); err != nil {
+			return nil, err
+		}
+		srv.server.TLSConfig = reloader.TLSConfig()
+	}
+	done := make(chan struct{})
+
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			logger.Info().
 				Str("port", cfg.Server.Port).
+				Bool("tls", reloader != nil).
+				Str("client_auth", cfg.Server.TLS.ClientAuth).
 				Msg("Starting HTTP server")
 
+			// Listen before returning so a taken port fails startup
+			ln, err := net.Listen("tcp", srv.server.Addr)
+			if err != nil {
+				return fmt.Errorf("failed to listen on %s: %w", srv.server.Addr, err)
+			}
+
+			serve := srv.server.Serve
+			if reloader != nil {
+				if err := reloader.Watch(done); err != nil {
+					ln.Close()
+					return err
+				}
+				// Certificates come from TLSConfig.GetCertificate
+				serve = func(ln net.Listener) error { return srv.server.ServeTLS(ln, "", "") }
+			}
+
 			go func() {
-				if err := srv.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				if err := serve(ln); err != nil && err != http.ErrServerClosed {
 					logger.Error().Err(err).Msg("Server error")
 				}
 			}()
-
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
+			defer close(done)
+			probes.Stopping()
+			if delay := cfg.Health.DrainDelay; delay > 0 {
+				logger.Info().Dur("delay", delay).Msg("Draining before shutdown")
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+				}
+			}
+
 			logger.Info().Msg("Stopping HTTP server")
-			return srv.server.Shutdown(ctx)
+			ctx, cancel := context.WithTimeout(ctx, cfg.Server.ShutdownTimeout)
+			defer cancel()
+			if err := srv.server.Shutdown(ctx); err != nil {
+				logger.Warn().Err(err).Msg("Requests still in flight after shutdown timeout, closing connections")
+				return srv.server.Close()
+			}
+			return nil
 		},
 	})
 
-	return srv
+	return srv, nil
 }
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/pagination"
+	"example.com/production-api/internal/repository"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// apiKeyPrefix marks API keys so they are recognisable in leaked logs
+// and secret scanners
+const apiKeyPrefix = "pak_"
+
+// lastUsedResolution limits how often last_used_at is written for a busy
+// key
+const lastUsedResolution = time.Minute
+
+// NewAPIKey is the input for creating an API key
+type NewAPIKey struct {
+	UserID    uint       `json:"user_id" validate:"required"`
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=users:write posts:write admin"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyService issues, rotates and verifies API keys. A key acts as its
+// owning user, limited to its scopes.
+type APIKeyService struct {
+	keys     repository.APIKeyRepository
+	users    repository.UserRepository
+	tx       repository.Transactor
+	validate *validator.Validate
+}
+
+// NewAPIKeyService creates an API key service
+func NewAPIKeyService(keys repository.APIKeyRepository, users repository.UserRepository, tx repository.Transactor) *APIKeyService {
+	return &APIKeyService{
+		keys:     keys,
+		users:    users,
+		tx:       tx,
+		validate: newValidator(),
+	}
+}
+
+// List returns a page of API keys
+func (s *APIKeyService) List(ctx context.Context, req *pagination.Request) ([]models.APIKey, *pagination.Page, error) {
+	return s.keys.List(ctx, req)
+}
+
+// Get returns a single API key
+func (s *APIKeyService) Get(ctx context.Context, id uint) (*models.APIKey, error) {
+	key, err := s.keys.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// Create issues a key for an existing user. The plaintext key is only
+// returned here and cannot be recovered later.
+func (s *APIKeyService) Create(ctx context.Context, in NewAPIKey) (*models.APIKey, string, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	if err := s.validate.Struct(in); err != nil {
+		return nil, "", err
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		return nil, "", ErrExpiryInPast
+	}
+
+	if _, err := s.users.Get(ctx, in.UserID, repository.UserQuery{}); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, "", ErrUserNotFound
+		}
+		return nil, "", err
+	}
+
+	return s.issue(ctx, &models.APIKey{
+		UserID:    in.UserID,
+		Name:      in.Name,
+		Scopes:    models.Scopes(in.Scopes),
+		ExpiresAt: in.ExpiresAt,
+	})
+}
+
+// Rotate issues a replacement for key id with the same owner, name,
+// scopes and expiry. The old key keeps working for overlap so clients
+// can be switched over without downtime. The replacement and the old
+// key's new expiry are stored together or not at all.
+func (s *APIKeyService) Rotate(ctx context.Context, id uint, overlap time.Duration) (*models.APIKey, string, error) {
+	if overlap < 0 {
+		return nil, "", ErrNegativeOverlap
+	}
+
+	var key *models.APIKey
+	var plaintext string
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		old, err := s.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if !old.Active(now) {
+			return ErrAPIKeyInactive
+		}
+
+		key, plaintext, err = s.issue(ctx, &models.APIKey{
+			UserID:        old.UserID,
+			Name:          old.Name,
+			Scopes:        old.Scopes,
+			ExpiresAt:     old.ExpiresAt,
+			RotatedFromID: &old.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		retireAt := now.Add(overlap)
+		if old.ExpiresAt == nil || retireAt.Before(*old.ExpiresAt) {
+			return s.keys.SetExpiry(ctx, old.ID, retireAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
+
+// Revoke disables a key immediately
+func (s *APIKeyService) Revoke(ctx context.Context, id uint) error {
+	err := s.keys.Revoke(ctx, id, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// AuthenticateKey implements auth.KeyAuthenticator
+func (s *APIKeyService) AuthenticateKey(ctx context.Context, plaintext string) (*auth.Principal, error) {
+	if !strings.HasPrefix(plaintext, apiKeyPrefix) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	key, err := s.keys.GetByHash(ctx, auth.HashToken(plaintext))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, auth.ErrInvalidToken
+	case err != nil:
+		return nil, err
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	user, err := s.users.Get(ctx, key.UserID, repository.UserQuery{})
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, auth.ErrInvalidToken
+	case err != nil:
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.keys.Touch(ctx, key.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return &auth.Principal{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Role:     user.Role,
+		APIKeyID: key.ID,
+		Scopes:   key.Scopes,
+	}, nil
+}
+
+// issue generates the secret for key and stores it
+func (s *APIKeyService) issue(ctx context.Context, key *models.APIKey) (*models.APIKey, string, error) {
+	secret, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := apiKeyPrefix + secret
+
+	key.Prefix = plaintext[:len(apiKeyPrefix)+8]
+	key.KeyHash = auth.HashToken(plaintext)
+	if err := s.keys.Create(ctx, key); err != nil {
+		if errors.Is(err, repository.ErrForeignKey) {
+			return nil, "", ErrUserNotFound
+		}
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/logging"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/repository"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Registration is the input for creating an account
+type Registration struct {
+	Name  string `json:"name" validate:"required,min=2"`
+	Email string `json:"email" validate:"required,email"`
+	// bcrypt ignores everything past 72 bytes
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// TokenPair is returned by login and refresh
+type TokenPair struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the access token lifetime in seconds
+	ExpiresIn        int       `json:"expires_in"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// AuthService registers users and issues tokens. Refresh tokens are
+// single use: every refresh revokes the presented token and issues a new
+// one in the same family. Presenting a revoked token revokes the family.
+type AuthService struct {
+	users         *UserService
+	userRepo      repository.UserRepository
+	refreshTokens repository.RefreshTokenRepository
+	tokens        *auth.TokenIssuer
+	validate      *validator.Validate
+}
+
+// NewAuthService creates an auth service
+func NewAuthService(
+	users *UserService,
+	userRepo repository.UserRepository,
+	refreshTokens repository.RefreshTokenRepository,
+	tokens *auth.TokenIssuer,
+) *AuthService {
+	return &AuthService{
+		users:         users,
+		userRepo:      userRepo,
+		refreshTokens: refreshTokens,
+		tokens:        tokens,
+		validate:      newValidator(),
+	}
+}
+
+// Register validates the registration and creates the user with a
+// hashed password
+func (s *AuthService) Register(ctx context.Context, reg Registration) (*models.User, error) {
+	reg.Name = strings.TrimSpace(reg.Name)
+	reg.Email = normalizeEmail(reg.Email)
+	if err := s.validate.Struct(reg); err != nil {
+		return nil, err
+	}
+
+	hash, err := auth.HashPassword(reg.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{Name: reg.Name, Email: reg.Email, Role: models.RoleUser, PasswordHash: hash}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login checks the credentials and starts a new token family
+func (s *AuthService) Login(ctx context.Context, email, password string) (*TokenPair, error) {
+	user, err := s.userRepo.GetByEmail(ctx, normalizeEmail(email))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	hash := ""
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if err := auth.CheckPassword(hash, password); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	family, err := newFamily()
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, user, family)
+}
+
+// Refresh exchanges a refresh token for a new token pair
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	stored, err := s.refreshTokens.GetByHash(ctx, auth.HashToken(refreshToken))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrInvalidToken
+	case err != nil:
+		return nil, err
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, s.revokeReused(ctx, stored)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	err = s.refreshTokens.Revoke(ctx, stored.ID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		// A concurrent refresh used the token first
+		return nil, s.revokeReused(ctx, stored)
+	case err != nil:
+		return nil, err
+	}
+
+	user, err := s.userRepo.Get(ctx, stored.UserID, repository.UserQuery{})
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrInvalidToken
+	case err != nil:
+		return nil, err
+	}
+
+	return s.issue(ctx, user, stored.Family)
+}
+
+// Logout revokes the token family of refreshToken. Unknown tokens are
+// ignored so logout is idempotent.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := s.refreshTokens.GetByHash(ctx, auth.HashToken(refreshToken))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	}
+	return s.refreshTokens.RevokeFamily(ctx, stored.Family)
+}
+
+// revokeReused ends the session of a refresh token that was presented
+// after it had been rotated: either the client or an attacker holds a
+// stolen copy, and we can't tell which
+func (s *AuthService) revokeReused(ctx context.Context, stored *models.RefreshToken) error {
+	logging.FromContext(ctx).Warn().
+		Uint("user_id", stored.UserID).
+		Str("family", stored.Family).
+		Msg("Refresh token reused; revoking token family")
+
+	if err := s.refreshTokens.RevokeFamily(ctx, stored.Family); err != nil {
+		return err
+	}
+	return ErrInvalidToken
+}
+
+// issue signs an access token and stores a new refresh token in family
+func (s *AuthService) issue(ctx context.Context, user *models.User, family string) (*TokenPair, error) {
+	access, err := s.tokens.Issue(auth.Principal{UserID: user.ID, Email: user.Email, Role: user.Role})
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	stored := &models.RefreshToken{
+		UserID:    user.ID,
+		Family:    family,
+		TokenHash: auth.HashToken(refresh),
+		ExpiresAt: time.Now().Add(s.tokens.RefreshTTL()),
+	}
+	if err := s.refreshTokens.Create(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(s.tokens.AccessTTL().Seconds()),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+func newFamily() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/repository"
+)
+
+// CertService resolves client certificates to the users configured in
+// server.tls.clientprincipals. The caller acts as that user, with the
+// user's role and without scope restrictions, so map certificates to
+// dedicated service accounts.
+type CertService struct {
+	users    repository.UserRepository
+	subjects map[string]string
+}
+
+// NewCertService creates a cert service from the TLS configuration
+func NewCertService(cfg *config.Config, users repository.UserRepository) *CertService {
+	subjects := make(map[string]string, len(cfg.Server.TLS.ClientPrincipals))
+	for _, cp := range cfg.Server.TLS.ClientPrincipals {
+		subjects[cp.Subject] = normalizeEmail(cp.Email)
+	}
+	return &CertService{users: users, subjects: subjects}
+}
+
+// AuthenticateCert implements auth.CertAuthenticator. The full subject
+// is matched before the common name.
+func (s *CertService) AuthenticateCert(ctx context.Context, cert *x509.Certificate) (*auth.Principal, error) {
+	email, ok := s.subjects[cert.Subject.String()]
+	if !ok {
+		email, ok = s.subjects[cert.Subject.CommonName]
+	}
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+
+	user, err := s.users.GetByEmail(ctx, email)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, auth.ErrInvalidToken
+	case err != nil:
+		return nil, err
+	}
+
+	return &auth.Principal{UserID: user.ID, Email: user.Email, Role: user.Role}, nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/repository"
+	"testing"
+)
+
+func TestAuthenticateCert(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository(repository.NewMemoryStore())
+	billing := &models.User{Name: "Billing", Email: "billing@example.com", Role: models.RoleUser}
+	ops := &models.User{Name: "Ops", Email: "ops@example.com", Role: models.RoleAdmin}
+	users.Create(ctx, billing)
+	users.Create(ctx, ops)
+
+	cfg := &config.Config{}
+	cfg.Server.TLS.ClientPrincipals = []config.ClientPrincipal{
+		{Subject: "billing", Email: "Billing@Example.com"},
+		{Subject: "CN=ops,O=Example", Email: "ops@example.com"},
+		{Subject: "gone", Email: "gone@example.com"},
+	}
+	s := NewCertService(cfg, users)
+
+	cert := func(cn string, org ...string) *x509.Certificate {
+		return &x509.Certificate{Subject: pkix.Name{CommonName: cn, Organization: org}}
+	}
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		want    uint
+		wantErr error
+	}{
+		{"common name", cert("billing", "Other"), billing.ID, nil},
+		{"full subject", cert("ops", "Example"), ops.ID, nil},
+		{"full subject with other organization", cert("ops", "Other"), 0, auth.ErrInvalidToken},
+		{"unmapped", cert("stranger"), 0, auth.ErrInvalidToken},
+		{"mapped to missing user", cert("gone"), 0, auth.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := s.AuthenticateCert(ctx, tt.cert)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if err == nil && p.UserID != tt.want {
+				t.Errorf("user = %d; want %d", p.UserID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/pagination"
+	"example.com/production-api/internal/repository"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// PostService implements post business rules
+type PostService struct {
+	posts    repository.PostRepository
+	users    repository.UserRepository
+	validate *validator.Validate
+}
+
+// NewPostService creates a post service
+func NewPostService(posts repository.PostRepository, users repository.UserRepository) *PostService {
+	return &PostService{
+		posts:    posts,
+		users:    users,
+		validate: newValidator(),
+	}
+}
+
+// List returns a page of posts matching q
+func (s *PostService) List(ctx context.Context, q repository.PostQuery, req *pagination.Request) ([]models.Post, *pagination.Page, error) {
+	return s.posts.List(ctx, q, req)
+}
+
+// Get returns a single post within q
+func (s *PostService) Get(ctx context.Context, id uint, q repository.PostQuery) (*models.Post, error) {
+	post, err := s.posts.Get(ctx, id, q)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrPostNotFound
+	}
+	return post, err
+}
+
+// Create validates and stores a new post for an existing user
+func (s *PostService) Create(ctx context.Context, post *models.Post) error {
+	post.ID = 0
+	post.User = nil
+	post.Title = strings.TrimSpace(post.Title)
+
+	if err := s.validate.Struct(post); err != nil {
+		return err
+	}
+
+	if _, err := s.users.Get(ctx, post.UserID, repository.UserQuery{}); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	err := s.posts.Create(ctx, post)
+	if errors.Is(err, repository.ErrForeignKey) {
+		// The author was deleted concurrently
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// PostInput holds the fields of a post that clients may write
+type PostInput struct {
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	Published bool   `json:"published"`
+}
+
+// Replace sets every writable field of a post from in
+func (s *PostService) Replace(ctx context.Context, id uint, q repository.PostQuery, in PostInput, pre Precondition) (*models.Post, error) {
+	return s.Patch(ctx, id, q, func(PostInput) (PostInput, error) { return in, nil }, pre)
+}
+
+// Patch derives a post's new writable fields from the current ones with
+// apply, then validates and saves the result
+func (s *PostService) Patch(ctx context.Context, id uint, q repository.PostQuery, apply func(PostInput) (PostInput, error), pre Precondition) (*models.Post, error) {
+	post, err := s.load(ctx, id, q, pre)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := apply(PostInput{Title: post.Title, Content: post.Content, Published: post.Published})
+	if err != nil {
+		return nil, err
+	}
+	post.Title = strings.TrimSpace(in.Title)
+	post.Content = in.Content
+	post.Published = in.Published
+
+	if err := s.validate.Struct(post); err != nil {
+		return nil, err
+	}
+	return post, s.save(ctx, post, pre)
+}
+
+// SetPublished publishes or unpublishes a post
+func (s *PostService) SetPublished(ctx context.Context, id uint, q repository.PostQuery, published bool, pre Precondition) (*models.Post, error) {
+	post, err := s.load(ctx, id, q, pre)
+	if err != nil {
+		return nil, err
+	}
+
+	post.Published = published
+	return post, s.save(ctx, post, pre)
+}
+
+// Delete removes a post within q
+func (s *PostService) Delete(ctx context.Context, id uint, q repository.PostQuery, pre Precondition) error {
+	post, err := s.load(ctx, id, q, pre)
+	if err != nil {
+		return err
+	}
+
+	var version uint
+	if pre.Versions != nil {
+		version = post.Version
+	}
+	err = s.posts.Delete(ctx, id, version)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrPostNotFound
+	}
+	return pre.conflict(err)
+}
+
+// load reads a post for a write and checks it against pre
+func (s *PostService) load(ctx context.Context, id uint, q repository.PostQuery, pre Precondition) (*models.Post, error) {
+	post, err := s.Get(ctx, id, q)
+	if err != nil {
+		return nil, err
+	}
+	if err := pre.check(post.Version); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (s *PostService) save(ctx context.Context, post *models.Post, pre Precondition) error {
+	err := s.posts.Update(ctx, post)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrPostNotFound
+	}
+	return pre.conflict(err)
+}
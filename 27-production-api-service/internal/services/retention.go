@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/repository"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// Purger permanently removes users and posts that have been soft-deleted
+// for longer than the retention window
+type Purger struct {
+	users  repository.UserRepository
+	posts  repository.PostRepository
+	window time.Duration
+}
+
+// NewPurger creates a purger for the configured retention window
+func NewPurger(cfg *config.Config, users repository.UserRepository, posts repository.PostRepository) *Purger {
+	return &Purger{users: users, posts: posts, window: cfg.Retention.Window}
+}
+
+// Purge removes records deleted before now minus the window. Posts go
+// first so their authors can follow in the same run.
+func (p *Purger) Purge(ctx context.Context, now time.Time) (users, posts int64, err error) {
+	if p.window <= 0 {
+		return 0, 0, nil
+	}
+
+	cutoff := now.Add(-p.window)
+	if posts, err = p.posts.Purge(ctx, cutoff); err != nil {
+		return 0, 0, err
+	}
+	users, err = p.users.Purge(ctx, cutoff)
+	return users, posts, err
+}
+
+// schedulePurge runs the purger every retention.purgeinterval
+func schedulePurge(lc fx.Lifecycle, cfg *config.Config, purger *Purger, logger zerolog.Logger) {
+	interval := cfg.Retention.PurgeInterval
+	if interval <= 0 || cfg.Retention.Window <= 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-done:
+						return
+					case now := <-ticker.C:
+						users, posts, err := purger.Purge(context.Background(), now)
+						if err != nil {
+							logger.Error().Err(err).Msg("Failed to purge deleted records")
+							continue
+						}
+						logger.Debug().Int64("users", users).Int64("posts", posts).Msg("Purged deleted records")
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(done)
+			return nil
+		},
+	})
+}
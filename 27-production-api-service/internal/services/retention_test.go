@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestPurgeRespectsRetentionWindow(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	users := repository.NewMemoryUserRepository(store)
+	posts := repository.NewMemoryPostRepository(store)
+
+	alice := &models.User{Name: "Alice", Email: "alice@example.com"}
+	bob := &models.User{Name: "Bob", Email: "bob@example.com"}
+	users.Create(ctx, alice)
+	users.Create(ctx, bob)
+	post := &models.Post{UserID: alice.ID, Title: "Hello"}
+	posts.Create(ctx, post)
+
+	posts.Delete(ctx, post.ID, 0)
+	users.Delete(ctx, alice.ID, 0)
+
+	cfg := &config.Config{Retention: config.RetentionConfig{Window: time.Hour}}
+	purger := NewPurger(cfg, users, posts)
+
+	if u, p, err := purger.Purge(ctx, time.Now()); err != nil || u != 0 || p != 0 {
+		t.Fatalf("within window: purged %d users, %d posts, err %v; want nothing", u, p, err)
+	}
+
+	u, p, err := purger.Purge(ctx, time.Now().Add(2*time.Hour))
+	if err != nil || u != 1 || p != 1 {
+		t.Fatalf("after window: purged %d users, %d posts, err %v; want 1 and 1", u, p, err)
+	}
+	if _, err := users.Restore(ctx, alice.ID); err != repository.ErrNotFound {
+		t.Errorf("restore purged user: err = %v; want ErrNotFound", err)
+	}
+	if _, err := users.Get(ctx, bob.ID, repository.UserQuery{}); err != nil {
+		t.Errorf("live user was purged: %v", err)
+	}
+}
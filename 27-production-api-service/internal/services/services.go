@@ -0,0 +1,101 @@
+// Package services holds the domain rules that sit between HTTP handlers
+// and repositories
+package services
+
+import (
+	"errors"
+	"example.com/production-api/internal/auth"
+	"example.com/production-api/internal/repository"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/fx"
+)
+
+// Module provides service dependencies
+var Module = fx.Options(
+	fx.Provide(NewUserService),
+	fx.Provide(NewPostService),
+	fx.Provide(NewAuthService),
+	fx.Provide(NewAPIKeyService),
+	fx.Provide(func(s *APIKeyService) auth.KeyAuthenticator { return s }),
+	fx.Provide(NewCertService),
+	fx.Provide(func(s *CertService) auth.CertAuthenticator { return s }),
+	fx.Provide(NewPurger),
+	fx.Invoke(schedulePurge),
+)
+
+// Domain errors returned by services
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrPostNotFound = errors.New("post not found")
+	ErrEmailTaken   = errors.New("email is already in use")
+	ErrUserHasPosts = errors.New("user still has posts")
+
+	ErrPreconditionFailed = errors.New("resource was modified since it was read")
+	ErrEditConflict       = errors.New("resource was modified concurrently")
+
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("refresh token is invalid or expired")
+
+	ErrAPIKeyNotFound  = errors.New("API key not found")
+	ErrAPIKeyInactive  = errors.New("API key is revoked or expired")
+	ErrExpiryInPast    = errors.New("expiry must be in the future")
+	ErrNegativeOverlap = errors.New("overlap must not be negative")
+)
+
+// Precondition limits a write to the versions a client has seen, as
+// given by If-Match
+type Precondition struct {
+	// Versions lists the acceptable versions. Nil accepts any version;
+	// an empty, non-nil slice accepts none.
+	Versions []uint
+}
+
+// Allows reports whether a resource at version may be written
+func (p Precondition) Allows(version uint) bool {
+	if p.Versions == nil {
+		return true
+	}
+	for _, v := range p.Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// check rejects writes to a version the client has not seen
+func (p Precondition) check(version uint) error {
+	if !p.Allows(version) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// conflict translates a lost race with another writer. A client that
+// sent If-Match gets the precondition error it asked for.
+func (p Precondition) conflict(err error) error {
+	if errors.Is(err, repository.ErrVersionConflict) {
+		if p.Versions != nil {
+			return ErrPreconditionFailed
+		}
+		return ErrEditConflict
+	}
+	return err
+}
+
+// newValidator creates a validator that reports fields by their JSON
+// names, so field errors match what clients sent
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"example.com/production-api/internal/models"
+	"example.com/production-api/internal/pagination"
+	"example.com/production-api/internal/repository"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// UserService implements user business rules
+type UserService struct {
+	users    repository.UserRepository
+	validate *validator.Validate
+}
+
+// NewUserService creates a user service
+func NewUserService(users repository.UserRepository) *UserService {
+	return &UserService{
+		users:    users,
+		validate: newValidator(),
+	}
+}
+
+// normalizeEmail makes emails comparable: addresses differing only in
+// case or surrounding whitespace belong to the same user
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// List returns a page of users matching q
+func (s *UserService) List(ctx context.Context, q repository.UserQuery, req *pagination.Request) ([]models.User, *pagination.Page, error) {
+	return s.users.List(ctx, q, req)
+}
+
+// Get returns a single user within q
+func (s *UserService) Get(ctx context.Context, id uint, q repository.UserQuery) (*models.User, error) {
+	user, err := s.users.Get(ctx, id, q)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// Create validates and stores a new user. Users get the user role
+// unless one is set.
+func (s *UserService) Create(ctx context.Context, user *models.User) error {
+	user.ID = 0
+	user.Name = strings.TrimSpace(user.Name)
+	user.Email = normalizeEmail(user.Email)
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+
+	if err := s.validate.Struct(user); err != nil {
+		return err
+	}
+	if err := s.ensureEmailAvailable(ctx, user.Email, 0); err != nil {
+		return err
+	}
+
+	err := s.users.Create(ctx, user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		// Lost a race with a concurrent create
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// UserInput holds the fields of a user that clients may write
+type UserInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Replace sets every writable field of the user with id from in
+func (s *UserService) Replace(ctx context.Context, id uint, in UserInput, pre Precondition) (*models.User, error) {
+	return s.Patch(ctx, id, func(UserInput) (UserInput, error) { return in, nil }, pre)
+}
+
+// Patch derives the user's new writable fields from the current ones
+// with apply, then validates and saves the result
+func (s *UserService) Patch(ctx context.Context, id uint, apply func(UserInput) (UserInput, error), pre Precondition) (*models.User, error) {
+	user, err := s.Get(ctx, id, repository.UserQuery{})
+	if err != nil {
+		return nil, err
+	}
+	if err := pre.check(user.Version); err != nil {
+		return nil, err
+	}
+
+	in, err := apply(UserInput{Name: user.Name, Email: user.Email})
+	if err != nil {
+		return nil, err
+	}
+	user.Name = strings.TrimSpace(in.Name)
+	user.Email = normalizeEmail(in.Email)
+
+	if err := s.validate.Struct(user); err != nil {
+		return nil, err
+	}
+	if err := s.ensureEmailAvailable(ctx, user.Email, user.ID); err != nil {
+		return nil, err
+	}
+
+	err = s.users.Update(ctx, user)
+	switch {
+	case errors.Is(err, repository.ErrDuplicate):
+		return nil, ErrEmailTaken
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrUserNotFound
+	case err != nil:
+		return nil, pre.conflict(err)
+	}
+	return user, nil
+}
+
+// Delete soft-deletes a user, who can be restored until the retention
+// window has passed. Users who still own posts cannot be deleted.
+func (s *UserService) Delete(ctx context.Context, id uint, pre Precondition) error {
+	var version uint
+	if pre.Versions != nil {
+		user, err := s.Get(ctx, id, repository.UserQuery{})
+		if err != nil {
+			return err
+		}
+		if err := pre.check(user.Version); err != nil {
+			return err
+		}
+		version = user.Version
+	}
+
+	err := s.users.Delete(ctx, id, version)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, repository.ErrForeignKey):
+		return ErrUserHasPosts
+	}
+	return pre.conflict(err)
+}
+
+// Restore undeletes a user. It fails if another user took the email in
+// the meantime.
+func (s *UserService) Restore(ctx context.Context, id uint) (*models.User, error) {
+	user, err := s.users.Restore(ctx, id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrUserNotFound
+	case errors.Is(err, repository.ErrDuplicate):
+		return nil, ErrEmailTaken
+	}
+	return user, err
+}
+
+// ensureEmailAvailable rejects emails used by another user
+func (s *UserService) ensureEmailAvailable(ctx context.Context, email string, exceptID uint) error {
+	existing, err := s.users.GetByEmail(ctx, email)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case existing.ID != exceptID:
+		return ErrEmailTaken
+	}
+	return nil
+}
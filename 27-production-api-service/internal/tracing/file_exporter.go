@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// newFileExporter exports spans as OTLP/JSON lines appended to path, the
+// format read by the OpenTelemetry Collector's otlpjsonfile receiver
+func newFileExporter(ctx context.Context, path string) (sdktrace.SpanExporter, error) {
+	return otlptrace.New(ctx, &fileClient{path: path})
+}
+
+// fileClient is an otlptrace.Client writing to a file instead of a
+// collector
+type fileClient struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Start implements otlptrace.Client
+func (c *fileClient) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	c.file = f
+	return nil
+}
+
+// Stop implements otlptrace.Client
+func (c *fileClient) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// UploadTraces implements otlptrace.Client. Each batch becomes one line
+// holding an ExportTraceServiceRequest.
+func (c *fileClient) UploadTraces(ctx context.Context, spans []*tracepb.ResourceSpans) error {
+	line, err := encodeBatch(spans)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return os.ErrClosed
+	}
+	_, err = c.file.Write(append(line, '\n'))
+	return err
+}
+
+// encodeBatch encodes spans as OTLP/JSON. That is protobuf JSON with
+// numeric enums, except that trace and span IDs are hex rather than
+// base64.
+func encodeBatch(spans []*tracepb.ResourceSpans) ([]byte, error) {
+	opts := protojson.MarshalOptions{UseEnumNumbers: true}
+	resourceSpans := make([]interface{}, 0, len(spans))
+	for _, rs := range spans {
+		data, err := opts.Marshal(rs)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		resourceSpans = append(resourceSpans, hexIDs(v))
+	}
+	return json.Marshal(map[string]interface{}{"resourceSpans": resourceSpans})
+}
+
+// hexIDs re-encodes the base64 IDs protojson produces as hex
+func hexIDs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			s, ok := value.(string)
+			if ok && (key == "traceId" || key == "spanId" || key == "parentSpanId") {
+				if id, err := base64.StdEncoding.DecodeString(s); err == nil {
+					v[key] = hex.EncodeToString(id)
+				}
+				continue
+			}
+			v[key] = hexIDs(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = hexIDs(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey holds the span of a running statement
+const spanKey = "tracing:span"
+
+// InstrumentDB wraps every GORM statement in a client span, a child of
+// the span in the statement's context (db.WithContext(r.Context()))
+func InstrumentDB(db *gorm.DB, tp trace.TracerProvider) error {
+	tracer := tp.Tracer(instrumentation)
+
+	start := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			name := "gorm." + operation
+			if tx.Statement.Table != "" {
+				name += " " + tx.Statement.Table
+			}
+			ctx, span := tracer.Start(tx.Statement.Context, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemPostgreSQL,
+					semconv.DBOperation(operation),
+				),
+			)
+			// Statements run by callbacks, such as the audit trail's,
+			// become children of this one
+			tx.Statement.Context = ctx
+			tx.InstanceSet(spanKey, span)
+		}
+	}
+	end := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		defer span.End()
+
+		if tx.Statement.Table != "" {
+			span.SetAttributes(semconv.DBSQLTable(tx.Statement.Table))
+		}
+		span.SetAttributes(
+			semconv.DBStatement(tx.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", tx.RowsAffected),
+		)
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			span.RecordError(tx.Error)
+			span.SetStatus(codes.Error, tx.Error.Error())
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("*").Register("tracing:before_create", start("create")),
+		cb.Create().After("*").Register("tracing:after_create", end),
+		cb.Query().Before("*").Register("tracing:before_query", start("query")),
+		cb.Query().After("*").Register("tracing:after_query", end),
+		cb.Update().Before("*").Register("tracing:before_update", start("update")),
+		cb.Update().After("*").Register("tracing:after_update", end),
+		cb.Delete().Before("*").Register("tracing:before_delete", start("delete")),
+		cb.Delete().After("*").Register("tracing:after_delete", end),
+		cb.Row().Before("*").Register("tracing:before_row", start("row")),
+		cb.Row().After("*").Register("tracing:after_row", end),
+		cb.Raw().Before("*").Register("tracing:before_raw", start("raw")),
+		cb.Raw().After("*").Register("tracing:after_raw", end),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Package tracing instruments HTTP requests and GORM statements with
+// OpenTelemetry spans and propagates W3C trace context. Spans go to the
+// exporter selected by tracing.exporter.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/fx"
+
+	"example.com/production-api/internal/config"
+	"example.com/production-api/internal/repository"
+)
+
+// Exporters accepted in tracing.exporter
+const (
+	ExporterNone     = "none"
+	ExporterStdout   = "stdout"
+	ExporterOTLPFile = "otlpfile"
+)
+
+// instrumentation names the tracer that creates this package's spans
+const instrumentation = "example.com/production-api/internal/tracing"
+
+// Propagator reads W3C traceparent, tracestate and baggage headers
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// Module provides the TracerProvider and, when spans are exported,
+// instruments the database
+func Module(cfg *config.Config) fx.Option {
+	if cfg.Tracing.Exporter == ExporterNone || cfg.Database.Driver == repository.DriverMemory {
+		return fx.Provide(NewProvider)
+	}
+
+	return fx.Options(
+		fx.Provide(NewProvider),
+		fx.Invoke(InstrumentDB),
+	)
+}
+
+// NewProvider creates the TracerProvider for the configured exporter.
+// Without an exporter spans are not recorded, but trace context from
+// callers still flows through to logs and responses.
+func NewProvider(lc fx.Lifecycle, cfg *config.Config) (trace.TracerProvider, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Tracing.Exporter {
+	case ExporterNone:
+		return noop.NewTracerProvider(), nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	case ExporterOTLPFile:
+		exporter, err = newFileExporter(context.Background(), cfg.Tracing.File)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Tracing.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", cfg.Tracing.Exporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(cfg.App.Name),
+			semconv.DeploymentEnvironment(cfg.App.Environment),
+		)),
+	)
+
+	// Shutdown flushes spans still waiting in the batcher
+	lc.Append(fx.Hook{
+		OnStop: tp.Shutdown,
+	})
+	return tp, nil
+}
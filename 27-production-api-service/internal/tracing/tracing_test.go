@@ -0,0 +1,196 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"example.com/production-api/internal/logging"
+	"example.com/production-api/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpanID  = "00f067aa0ba902b7"
+	traceparent   = "00-" + parentTraceID + "-" + parentSpanID + "-01"
+)
+
+func newRecorder() (*tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	rec := tracetest.NewSpanRecorder()
+	return rec, sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+}
+
+func attr(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	rec, tp := newRecorder()
+	var logs bytes.Buffer
+
+	r := chi.NewRouter()
+	r.Use(Middleware(tp))
+	r.Use(logging.Middleware(zerolog.New(&logs)))
+	r.Get("/api/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/api/boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest("GET", "/api/users/7", nil)
+	req.Header.Set("traceparent", traceparent)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/boom", nil))
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans; want 2", len(spans))
+	}
+	span := spans[0]
+	sc := span.SpanContext()
+	if span.Name() != "GET /api/users/{id}" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("span = %q kind %v; want server span GET /api/users/{id}", span.Name(), span.SpanKind())
+	}
+	if sc.TraceID().String() != parentTraceID || span.Parent().SpanID().String() != parentSpanID || !span.Parent().IsRemote() {
+		t.Errorf("span %s with parent %s; want child of remote %s", sc.TraceID(), span.Parent().SpanID(), parentSpanID)
+	}
+	if got := attr(span, "http.route"); got != "/api/users/{id}" {
+		t.Errorf("http.route = %q", got)
+	}
+
+	want := "00-" + parentTraceID + "-" + sc.SpanID().String() + "-01"
+	if got := w.Header().Get("traceparent"); got != want {
+		t.Errorf("response traceparent = %q; want %q", got, want)
+	}
+	if !strings.Contains(logs.String(), `"trace_id":"`+parentTraceID+`","span_id":"`+sc.SpanID().String()+`"`) {
+		t.Errorf("access log lacks trace and span IDs: %s", logs.String())
+	}
+
+	if boom := spans[1]; boom.Status().Code != codes.Error || boom.Parent().IsValid() {
+		t.Errorf("5xx span: status %v, parent %v; want error status on a new trace", boom.Status(), boom.Parent())
+	}
+}
+
+func TestInstrumentDB(t *testing.T) {
+	rec, tp := newRecorder()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InstrumentDB(db, tp); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	db.WithContext(ctx).Create(&models.User{Name: "Alice", Email: "alice@example.com"})
+	db.WithContext(ctx).First(&models.User{}, 1)
+	parent.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans; want 3", len(spans))
+	}
+	for i, want := range []string{"gorm.create users", "gorm.query users"} {
+		span := spans[i]
+		if span.Name() != want || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %d = %q child of %s; want %q child of the request", i, span.Name(), span.Parent().SpanID(), want)
+		}
+		if attr(span, "db.system") != "postgresql" || attr(span, "db.sql.table") != "users" {
+			t.Errorf("span %q attributes = %v", span.Name(), span.Attributes())
+		}
+	}
+	if stmt := attr(spans[0], "db.statement"); !strings.HasPrefix(stmt, `INSERT INTO "users"`) {
+		t.Errorf("db.statement = %q", stmt)
+	}
+}
+
+func TestTransportPropagates(t *testing.T) {
+	rec, tp := newRecorder()
+	var got string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("traceparent")
+	}))
+	defer backend.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	req, _ := http.NewRequestWithContext(ctx, "GET", backend.URL, nil)
+	client := &http.Client{Transport: NewTransport(nil, tp)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	span := rec.Ended()[0]
+	want := "00-" + parent.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got != want || span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("backend saw traceparent %q; want %q from a client span", got, want)
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Error("caller's request was modified")
+	}
+}
+
+func TestFileExporterWritesOTLPJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := newFileExporter(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	_, span := tp.Tracer("test").Start(context.Background(), "work")
+	span.End()
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batch struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					SpanID  string `json:"spanId"`
+					Name    string `json:"name"`
+					Kind    int    `json:"kind"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(data), &batch); err != nil {
+		t.Fatalf("not one OTLP/JSON line: %v\n%s", err, data)
+	}
+	got := batch.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	sc := span.SpanContext()
+	if got.Name != "work" || got.TraceID != sc.TraceID().String() || got.SpanID != sc.SpanID().String() || got.Kind != int(trace.SpanKindInternal) {
+		t.Errorf("exported %+v; want span work with hex IDs %s/%s", got, sc.TraceID(), sc.SpanID())
+	}
+}
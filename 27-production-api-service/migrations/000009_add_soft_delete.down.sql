@@ -0,0 +1,13 @@
+-- Soft-deleted rows cannot be represented without the column, and may
+-- share emails with live users, so they are purged
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS users_email_active_key;
+CREATE UNIQUE INDEX idx_users_email ON users(email);
+
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+DROP INDEX IF EXISTS idx_users_deleted_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_users_deleted_at ON users(deleted_at);
+CREATE INDEX idx_posts_deleted_at ON posts(deleted_at);
+
+-- Deleted users keep their email, so it only has to be unique among
+-- live users
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX users_email_active_key ON users(email) WHERE deleted_at IS NULL;
@@ -0,0 +1,17 @@
+-- Entries outlive the users and posts they describe, so actor_id and
+-- resource_id are deliberately not foreign keys
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id SERIAL PRIMARY KEY,
+    resource VARCHAR(50) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    actor_id INTEGER NOT NULL DEFAULT 0,
+    api_key_id INTEGER NOT NULL DEFAULT 0,
+    request_id VARCHAR(100) NOT NULL DEFAULT '',
+    changes JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_entries_resource ON audit_entries(resource, resource_id);
+CREATE INDEX idx_audit_entries_actor_id ON audit_entries(actor_id);
+CREATE INDEX idx_audit_entries_created_at ON audit_entries(created_at);
@@ -0,0 +1,12 @@
+// Package migrations embeds the versioned SQL migrations into the binary.
+//
+// Files follow the golang-migrate naming scheme, so `make migrate-create`
+// keeps working: NNNNNN_name.up.sql and NNNNNN_name.down.sql.
+package migrations
+
+import "embed"
+
+// FS holds every migration file
+//
+//go:embed *.sql
+var FS embed.FS